@@ -2,25 +2,105 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"net/http"
+	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
-	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/api"
 	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/discovery/dns"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/discovery/file"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/discovery/kubernetes"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/discovery/strimzi"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/exporter/otlp"
 	exporter "github.com/Ecube-Labs/kafka-connect-exporter/internal/exporter/prometheus"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/notifier"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/probe"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/remediation"
 	"github.com/Ecube-Labs/kafka-connect-exporter/internal/server"
 	applicationError "github.com/Ecube-Labs/kafka-connect-exporter/pkg/application-error"
 	"github.com/Ecube-Labs/kafka-connect-exporter/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+// how long the start waits for the first targets of a discoverer, which keeps trying in the background afterwards
+const discoveryTimeout = 30 * time.Second
+
 func main() {
-	collector := collector.New(&http.Client{Timeout: 10 * time.Second})
-	exporter := exporter.New(collector)
+	configFile := flag.String("config.file", os.Getenv("CONFIG_FILE"), "Path to the YAML configuration file")
+	flag.Parse()
+
+	config, err := config.Load(*configFile)
+	if err != nil {
+		logger.Log("error", applicationError.UnWrap(err).Stack)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	targets, err := poller.NewTargets(config.Clusters)
+	if err != nil {
+		logger.Log("error", applicationError.UnWrap(err).Stack)
+		os.Exit(1)
+	}
+	poller := poller.New(targets, config.PollInterval)
+	if err := startDiscovery(ctx, config, poller); err != nil {
+		logger.Log("error", applicationError.UnWrap(err).Stack)
+		os.Exit(1)
+	}
+	api := api.New(poller)
+
+	if config.Remediation.Enabled {
+		remediator, err := remediation.New(config.Remediation)
+		if err != nil {
+			logger.Log("error", applicationError.UnWrap(err).Stack)
+			os.Exit(1)
+		}
+		prometheus.MustRegister(remediator)
+		poller.AddListener(remediator.Remediate)
+	}
+
+	if len(config.Notifications.Webhooks) > 0 {
+		notifier, err := notifier.New(config.Notifications)
+		if err != nil {
+			logger.Log("error", applicationError.UnWrap(err).Stack)
+			os.Exit(1)
+		}
+		poller.AddListener(notifier.Notify)
+		go notifier.Run(ctx)
+	}
 
 	mux := http.NewServeMux()
-	mux.Handle(config.MetricsEndpoint, exporter.Handler())
+	if config.ExportsPrometheus() {
+		exporter := exporter.New(poller)
+		mux.Handle(config.MetricsEndpoint, exporter.Handler())
+	}
+
+	if modules := config.ProbeModules(); len(modules) > 0 {
+		prober, err := probe.New(modules)
+		if err != nil {
+			logger.Log("error", applicationError.UnWrap(err).Stack)
+			os.Exit(1)
+		}
+		mux.Handle(config.ProbeEndpoint, prober.Handler())
+	}
+
+	var otlpExporter *otlp.Exporter
+	if config.ExportsOTLP() {
+		otlpExporter, err = otlp.New(ctx, config.OTLP, config.Clusters, poller)
+		if err != nil {
+			logger.Log("error", applicationError.UnWrap(err).Stack)
+			os.Exit(1)
+		}
+	}
+	mux.Handle("/api/", api.Handler())
 	mux.HandleFunc(config.HealthCheckEndpoint, func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
@@ -28,8 +108,8 @@ func main() {
 
 	server := server.New(config.Port, mux)
 	logger.Log("info", "Starting Kafka Connect Exporter")
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
+
+	go poller.Run(ctx)
 
 	go func() {
 		if err := server.Run(); err != nil {
@@ -46,4 +126,101 @@ func main() {
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		logger.Log("error", applicationError.UnWrap(err).Stack)
 	}
+	if otlpExporter != nil {
+		if err := otlpExporter.Shutdown(shutdownCtx); err != nil {
+			logger.Log("error", applicationError.UnWrap(err).Stack)
+		}
+	}
+}
+
+// Add the discoverers of the clusters to the poller and wait for their first targets, all of them in parallel
+func startDiscovery(ctx context.Context, settings *config.Config, p *poller.Poller) error {
+	waitCtx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+	defer cancel()
+	var wait sync.WaitGroup
+
+	for _, cluster := range settings.Clusters {
+		if err := startClusterDiscovery(ctx, waitCtx, &wait, settings, cluster, p); err != nil {
+			return err
+		}
+	}
+
+	if settings.Strimzi.Enabled {
+		module := settings.Modules[settings.Strimzi.Module]
+		target, err := poller.NewClusterTarget(module.Cluster(settings.Strimzi.Module))
+		if err != nil {
+			return err
+		}
+		client, err := strimzi.NewClient(settings.Strimzi.Kubeconfig)
+		if err != nil {
+			return err
+		}
+		discoverer, err := strimzi.New(client, target, settings.Strimzi)
+		if err != nil {
+			return err
+		}
+
+		p.AddDiscoverer(discoverer)
+		prometheus.MustRegister(discoverer.DriftCollector(p))
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			if !discoverer.Start(ctx, waitCtx) {
+				logger.Log("error", fmt.Sprintf("Strimzi discovery is not ready after %s", discoveryTimeout))
+			}
+		}()
+	}
+	wait.Wait()
+	return nil
+}
+
+// Add the discoverers of the urls of a cluster resolved with DNS, read from files or found in kubernetes.
+// The kubernetes discoverer is started in the background and waits for its first targets until waitCtx is done.
+func startClusterDiscovery(ctx, waitCtx context.Context, wait *sync.WaitGroup, settings *config.Config, cluster config.Cluster, p *poller.Poller) error {
+	dnsURLs := cluster.DNSURLs()
+	if len(dnsURLs) == 0 && cluster.FileSD == nil && cluster.Kubernetes == nil {
+		return nil
+	}
+	target, err := poller.NewClusterTarget(cluster)
+	if err != nil {
+		return err
+	}
+
+	if len(dnsURLs) > 0 {
+		discoverer, err := dns.New(dns.NewResolver(settings.DNS.Resolver), target, dnsURLs, settings.DNS)
+		if err != nil {
+			return err
+		}
+		discoverer.Start(ctx)
+		p.AddDiscoverer(discoverer)
+		prometheus.MustRegister(discoverer)
+	}
+
+	if cluster.FileSD != nil {
+		discoverer := file.New(target, *cluster.FileSD)
+		discoverer.Start(ctx)
+		p.AddDiscoverer(discoverer)
+		prometheus.MustRegister(discoverer)
+	}
+
+	if cluster.Kubernetes != nil {
+		client, err := kubernetes.NewClient(cluster.Kubernetes.Kubeconfig)
+		if err != nil {
+			return err
+		}
+		discoverer, err := kubernetes.New(client, target, *cluster.Kubernetes)
+		if err != nil {
+			return err
+		}
+
+		p.AddDiscoverer(discoverer)
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			if !discoverer.Start(ctx, waitCtx) {
+				logger.Log("error", fmt.Sprintf("Kubernetes discovery of cluster %s is not ready after %s", cluster.Name, discoveryTimeout))
+			}
+		}()
+	}
+	return nil
 }
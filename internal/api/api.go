@@ -0,0 +1,224 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	applicationError "github.com/Ecube-Labs/kafka-connect-exporter/pkg/application-error"
+	"github.com/Ecube-Labs/kafka-connect-exporter/pkg/logger"
+)
+
+// JSON endpoints serving the latest snapshot of the poller
+type API struct {
+	poller *poller.Poller
+
+	mu sync.RWMutex
+	// the last successful poll of every host of the latest snapshot, so that the connectors of a failing host are still served
+	lastSeen map[string]*poller.HostSnapshot
+}
+
+// New must be called before the poller is started, as it listens to the snapshots of the poller
+func New(poller *poller.Poller) *API {
+	api := &API{
+		poller: poller,
+	}
+	if snapshot := poller.Snapshot(); snapshot != nil {
+		api.record(snapshot)
+	}
+	poller.AddListener(api.record)
+	return api
+}
+
+func (a *API) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/traces", a.getTraces)
+	mux.HandleFunc("GET /api/v1/connectors", a.getConnectors)
+	mux.HandleFunc("GET /api/v1/clusters/{cluster}/connectors/{connector}", a.getConnector)
+	return mux
+}
+
+func (a *API) record(snapshot *poller.Snapshot) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.lastSeen == nil {
+		a.lastSeen = map[string]*poller.HostSnapshot{}
+	}
+	current := make(map[string]bool, len(snapshot.Hosts))
+	for _, host := range snapshot.Hosts {
+		current[host.Host] = true
+		if host.Err == nil {
+			a.lastSeen[host.Host] = host
+		}
+	}
+	// forget the hosts that are no longer polled, e.g. pods that were replaced
+	for host := range a.lastSeen {
+		if !current[host] {
+			delete(a.lastSeen, host)
+		}
+	}
+}
+
+// The status of every connector of every host, optionally filtered by the cluster, host and state query parameters.
+// A connector matches a state when the connector or any of its tasks is in that state.
+func (a *API) getConnectors(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	connectors := a.connectorStatuses(func(host *poller.HostSnapshot, name string, status *connectorStatus) bool {
+		return matchQuery(query.Get("cluster"), host.Cluster) && matchQuery(query.Get("host"), host.Host) && status.hasState(query.Get("state"))
+	})
+	writeJSON(w, http.StatusOK, connectors)
+}
+
+// The status of a connector on every host of a cluster
+func (a *API) getConnector(w http.ResponseWriter, r *http.Request) {
+	cluster, connector := r.PathValue("cluster"), r.PathValue("connector")
+	connectors := a.connectorStatuses(func(host *poller.HostSnapshot, name string, status *connectorStatus) bool {
+		return host.Cluster == cluster && name == connector
+	})
+	if len(connectors) == 0 {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: fmt.Sprintf("connector %s not found in cluster %s", connector, cluster)})
+		return
+	}
+	writeJSON(w, http.StatusOK, connectors)
+}
+
+// The last seen status of the connectors of the hosts in the latest snapshot that match the filter, sorted by cluster, host and name
+func (a *API) connectorStatuses(filter func(host *poller.HostSnapshot, name string, status *connectorStatus) bool) []connectorStatus {
+	connectors := []connectorStatus{}
+
+	snapshot := a.poller.Snapshot()
+	if snapshot == nil {
+		return connectors
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, current := range snapshot.Hosts {
+		host, ok := a.lastSeen[current.Host]
+		if !ok {
+			continue
+		}
+		for name, connector := range host.Connectors {
+			status := newConnectorStatus(host, name, connector)
+			status.HostUp = current.Err == nil
+			if filter(host, name, &status) {
+				connectors = append(connectors, status)
+			}
+		}
+	}
+
+	sort.Slice(connectors, func(i, j int) bool {
+		if connectors[i].Cluster != connectors[j].Cluster {
+			return connectors[i].Cluster < connectors[j].Cluster
+		}
+		if connectors[i].Host != connectors[j].Host {
+			return connectors[i].Host < connectors[j].Host
+		}
+		return connectors[i].Name < connectors[j].Name
+	})
+	return connectors
+}
+
+func newConnectorStatus(host *poller.HostSnapshot, name string, connector *collector.ExpandedConnector) connectorStatus {
+	status := connectorStatus{
+		Cluster:  host.Cluster,
+		Host:     host.Host,
+		Name:     name,
+		Tasks:    []taskStatus{},
+		LastSeen: host.Time,
+	}
+	if connector.Info != nil {
+		status.Type = connector.Info.Type
+		status.Class = connector.Info.Config["connector.class"]
+	}
+	if connector.Status == nil {
+		return status
+	}
+	if status.Type == "" {
+		status.Type = connector.Status.Type
+	}
+	status.State = connector.Status.Connector.State
+	status.WorkerID = connector.Status.Connector.WorkerID
+	for _, task := range connector.Status.Tasks {
+		taskStatus := taskStatus{ID: task.ID, State: task.State, WorkerID: task.WorkerID, Trace: task.Trace}
+		if task.State == "FAILED" {
+			taskStatus.Exception = collector.RootException(task.Trace)
+		}
+		status.Tasks = append(status.Tasks, taskStatus)
+	}
+	sort.Slice(status.Tasks, func(i, j int) bool { return status.Tasks[i].ID < status.Tasks[j].ID })
+	return status
+}
+
+// Full stack traces of all failed tasks, optionally filtered by the cluster, host and connector query parameters
+func (a *API) getTraces(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	traces := []taskTrace{}
+
+	snapshot := a.poller.Snapshot()
+	if snapshot == nil {
+		writeJSON(w, http.StatusOK, traces)
+		return
+	}
+
+	for _, host := range snapshot.Hosts {
+		if !matchQuery(query.Get("cluster"), host.Cluster) || !matchQuery(query.Get("host"), host.Host) {
+			continue
+		}
+		for name, connector := range host.Connectors {
+			if !matchQuery(query.Get("connector"), name) || connector.Status == nil {
+				continue
+			}
+			for _, task := range connector.Status.Tasks {
+				if task.State != "FAILED" {
+					continue
+				}
+				traces = append(traces, taskTrace{
+					Cluster:   host.Cluster,
+					Host:      host.Host,
+					Connector: name,
+					Task:      task.ID,
+					WorkerID:  task.WorkerID,
+					Exception: collector.RootException(task.Trace),
+					Trace:     task.Trace,
+				})
+			}
+		}
+	}
+
+	sort.Slice(traces, func(i, j int) bool {
+		if traces[i].Host != traces[j].Host {
+			return traces[i].Host < traces[j].Host
+		}
+		if traces[i].Connector != traces[j].Connector {
+			return traces[i].Connector < traces[j].Connector
+		}
+		return traces[i].Task < traces[j].Task
+	})
+	writeJSON(w, http.StatusOK, traces)
+}
+
+// An empty query parameter matches everything
+func matchQuery(expected, actual string) bool {
+	return expected == "" || expected == actual
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	content, err := json.Marshal(body)
+	if err != nil {
+		logger.Log("error", applicationError.UnWrap(err).Stack)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.WriteHeader(status)
+	w.Write(content)
+}
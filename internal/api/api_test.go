@@ -0,0 +1,215 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockRoundTripper struct {
+	roundTripFunc func(req *http.Request) (*http.Response, error)
+}
+
+func (m *mockRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return m.roundTripFunc(req)
+}
+
+const mockConnectors = `{
+	"connector1": {"status": {"name": "connector1", "connector": {"state": "RUNNING", "worker_id": "worker1:8083"}, "tasks": [
+		{"id": 0, "state": "RUNNING", "worker_id": "worker1:8083"},
+		{"id": 1, "state": "FAILED", "worker_id": "worker2:8083", "trace": "org.apache.kafka.connect.errors.ConnectException: failed\nCaused by: java.net.ConnectException: Connection refused"}
+	]}},
+	"connector2": {"status": {"name": "connector2", "connector": {"state": "FAILED", "worker_id": "worker1:8083"}, "tasks": [
+		{"id": 0, "state": "FAILED", "worker_id": "worker1:8083", "trace": "java.lang.NullPointerException"}
+	]}}
+}`
+
+// Create a polled poller with a "prod" cluster of two hosts and a "staging" cluster of one host, all serving the same connectors
+func newPoller() *poller.Poller {
+	roundTripper := &mockRoundTripper{
+		roundTripFunc: func(req *http.Request) (*http.Response, error) {
+			response := httptest.NewRecorder()
+			if req.URL.Path == "/connectors" {
+				response.Write([]byte(mockConnectors))
+			} else {
+				response.Write([]byte(`[]`))
+			}
+			return response.Result(), nil
+		},
+	}
+	collector := collector.New(&http.Client{Transport: roundTripper})
+
+	poller := poller.New([]poller.Target{
+		{Cluster: "prod", Host: "http://prod-1:8083", Collector: collector},
+		{Cluster: "prod", Host: "http://prod-2:8083", Collector: collector},
+		{Cluster: "staging", Host: "http://staging:8083", Collector: collector},
+	}, time.Minute)
+	poller.Poll()
+	return poller
+}
+
+func get(t *testing.T, handler http.Handler, target string, body any) *httptest.ResponseRecorder {
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, target, nil))
+	if body != nil && response.Code == http.StatusOK {
+		if err := json.Unmarshal(response.Body.Bytes(), body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return response
+}
+
+func TestGetTraces(t *testing.T) {
+	handler := New(newPoller()).Handler()
+
+	t.Run("Should return the traces of all failed tasks", func(t *testing.T) {
+		var traces []taskTrace
+		response := get(t, handler, "/api/v1/traces", &traces)
+
+		assert.Equal(t, http.StatusOK, response.Code)
+		assert.Equal(t, "application/json", response.Header().Get("Content-Type"))
+		assert.Len(t, traces, 6)
+		assert.Equal(t, taskTrace{
+			Cluster:   "prod",
+			Host:      "http://prod-1:8083",
+			Connector: "connector1",
+			Task:      1,
+			WorkerID:  "worker2:8083",
+			Exception: "java.net.ConnectException",
+			Trace:     "org.apache.kafka.connect.errors.ConnectException: failed\nCaused by: java.net.ConnectException: Connection refused",
+		}, traces[0])
+	})
+
+	t.Run("Should filter traces by cluster, host and connector", func(t *testing.T) {
+		var traces []taskTrace
+		get(t, handler, "/api/v1/traces?cluster=prod&connector=connector2", &traces)
+		assert.Len(t, traces, 2)
+
+		get(t, handler, "/api/v1/traces?host=http://staging:8083", &traces)
+		assert.Len(t, traces, 2)
+		assert.Equal(t, "staging", traces[0].Cluster)
+	})
+
+	t.Run("Should return an empty list before the first poll", func(t *testing.T) {
+		handler := New(poller.New(nil, time.Minute)).Handler()
+
+		response := get(t, handler, "/api/v1/traces", nil)
+		assert.Equal(t, http.StatusOK, response.Code)
+		assert.JSONEq(t, `[]`, response.Body.String())
+	})
+}
+
+func TestGetConnectors(t *testing.T) {
+	handler := New(newPoller()).Handler()
+
+	t.Run("Should return the status of every connector of every host", func(t *testing.T) {
+		var connectors []connectorStatus
+		response := get(t, handler, "/api/v1/connectors", &connectors)
+
+		assert.Equal(t, http.StatusOK, response.Code)
+		assert.Len(t, connectors, 6)
+		assert.Equal(t, "prod", connectors[0].Cluster)
+		assert.Equal(t, "http://prod-1:8083", connectors[0].Host)
+		assert.Equal(t, "connector1", connectors[0].Name)
+		assert.Equal(t, "RUNNING", connectors[0].State)
+		assert.Equal(t, "worker1:8083", connectors[0].WorkerID)
+		assert.True(t, connectors[0].HostUp)
+		assert.False(t, connectors[0].LastSeen.IsZero())
+		assert.Equal(t, []taskStatus{
+			{ID: 0, State: "RUNNING", WorkerID: "worker1:8083"},
+			{ID: 1, State: "FAILED", WorkerID: "worker2:8083", Exception: "java.net.ConnectException", Trace: "org.apache.kafka.connect.errors.ConnectException: failed\nCaused by: java.net.ConnectException: Connection refused"},
+		}, connectors[0].Tasks)
+	})
+
+	t.Run("Should filter connectors by cluster and state", func(t *testing.T) {
+		var connectors []connectorStatus
+		get(t, handler, "/api/v1/connectors?cluster=staging", &connectors)
+		assert.Len(t, connectors, 2)
+
+		// connector1 is running, but has a failed task
+		get(t, handler, "/api/v1/connectors?cluster=prod&state=FAILED", &connectors)
+		assert.Len(t, connectors, 4)
+
+		get(t, handler, "/api/v1/connectors?state=RUNNING", &connectors)
+		assert.Len(t, connectors, 3)
+		for _, connector := range connectors {
+			assert.Equal(t, "connector1", connector.Name)
+		}
+	})
+
+	t.Run("Should keep the last seen status of a failing host", func(t *testing.T) {
+		var failing atomic.Bool
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				if failing.Load() {
+					response.WriteHeader(http.StatusInternalServerError)
+				} else if req.URL.Path == "/connectors" {
+					response.Write([]byte(mockConnectors))
+				} else {
+					response.Write([]byte(`[]`))
+				}
+				return response.Result(), nil
+			},
+		}
+		poller := poller.New([]poller.Target{{Cluster: "prod", Host: "http://prod-1:8083", Collector: collector.New(&http.Client{Transport: roundTripper})}}, time.Minute)
+		handler := New(poller).Handler()
+
+		poller.Poll()
+		var connectors []connectorStatus
+		get(t, handler, "/api/v1/connectors", &connectors)
+		lastSeen := connectors[0].LastSeen
+
+		failing.Store(true)
+		poller.Poll()
+		get(t, handler, "/api/v1/connectors", &connectors)
+		assert.Len(t, connectors, 2)
+		assert.False(t, connectors[0].HostUp)
+		assert.Equal(t, lastSeen, connectors[0].LastSeen)
+	})
+
+	t.Run("Should forget the hosts that are no longer polled", func(t *testing.T) {
+		api := New(newPoller())
+		assert.Len(t, api.lastSeen, 3)
+
+		api.record(&poller.Snapshot{Time: time.Now(), Hosts: []*poller.HostSnapshot{{Cluster: "prod", Host: "http://prod-1:8083", Err: assert.AnError}}})
+		assert.Len(t, api.lastSeen, 1)
+		assert.Contains(t, api.lastSeen, "http://prod-1:8083")
+	})
+
+	t.Run("Should return an empty list before the first poll", func(t *testing.T) {
+		handler := New(poller.New(nil, time.Minute)).Handler()
+
+		response := get(t, handler, "/api/v1/connectors", nil)
+		assert.Equal(t, http.StatusOK, response.Code)
+		assert.JSONEq(t, `[]`, response.Body.String())
+	})
+}
+
+func TestGetConnector(t *testing.T) {
+	handler := New(newPoller()).Handler()
+
+	t.Run("Should return the status of a connector on every host of the cluster", func(t *testing.T) {
+		var connectors []connectorStatus
+		response := get(t, handler, "/api/v1/clusters/prod/connectors/connector2", &connectors)
+
+		assert.Equal(t, http.StatusOK, response.Code)
+		assert.Len(t, connectors, 2)
+		assert.Equal(t, "http://prod-1:8083", connectors[0].Host)
+		assert.Equal(t, "http://prod-2:8083", connectors[1].Host)
+		assert.Equal(t, "FAILED", connectors[1].State)
+	})
+
+	t.Run("Should return not found for an unknown connector", func(t *testing.T) {
+		response := get(t, handler, "/api/v1/clusters/prod/connectors/unknown", nil)
+		assert.Equal(t, http.StatusNotFound, response.Code)
+		assert.JSONEq(t, `{"error": "connector unknown not found in cluster prod"}`, response.Body.String())
+	})
+}
@@ -0,0 +1,53 @@
+package api
+
+import "time"
+
+type taskTrace struct {
+	Cluster   string `json:"cluster"`
+	Host      string `json:"host"`
+	Connector string `json:"connector"`
+	Task      int    `json:"task"`
+	WorkerID  string `json:"worker_id"`
+	Exception string `json:"exception"`
+	Trace     string `json:"trace"`
+}
+
+type connectorStatus struct {
+	Cluster  string       `json:"cluster"`
+	Host     string       `json:"host"`
+	Name     string       `json:"name"`
+	Type     string       `json:"type,omitempty"`
+	Class    string       `json:"class,omitempty"`
+	State    string       `json:"state"`
+	WorkerID string       `json:"worker_id"`
+	Tasks    []taskStatus `json:"tasks"`
+	// time of the last successful poll of the host that reported the connector
+	LastSeen time.Time `json:"last_seen"`
+	// whether the latest poll of the host succeeded, otherwise the status is the last one seen
+	HostUp bool `json:"host_up"`
+}
+
+type taskStatus struct {
+	ID        int    `json:"id"`
+	State     string `json:"state"`
+	WorkerID  string `json:"worker_id"`
+	Exception string `json:"exception,omitempty"`
+	Trace     string `json:"trace,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// An empty state matches every connector
+func (s *connectorStatus) hasState(state string) bool {
+	if state == "" || s.State == state {
+		return true
+	}
+	for _, task := range s.Tasks {
+		if task.State == state {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+)
+
+// A credential that is either given inline or read from a file, which is read again when it changes
+func newSecret(value config.Secret, path string) func() (string, error) {
+	if path == "" {
+		return func() (string, error) {
+			return string(value), nil
+		}
+	}
+
+	secret := newReloadable(func() (string, error) {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(content)), nil
+	}, path)
+	return func() (string, error) {
+		value, err := secret.get()
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file %s: %w", path, err)
+		}
+		return value, nil
+	}
+}
+
+// Adds the credentials to every request
+type authRoundTripper struct {
+	next      http.RoundTripper
+	authorize func(req *http.Request) error
+}
+
+func (a *authRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the given request
+	req = req.Clone(req.Context())
+	if err := a.authorize(req); err != nil {
+		return nil, err
+	}
+	return a.next.RoundTrip(req)
+}
+
+func withAuth(next http.RoundTripper, auth config.Auth) http.RoundTripper {
+	switch {
+	case auth.Basic != nil:
+		username := auth.Basic.Username
+		password := newSecret(auth.Basic.Password, auth.Basic.PasswordFile)
+		return &authRoundTripper{next: next, authorize: func(req *http.Request) error {
+			value, err := password()
+			if err != nil {
+				return err
+			}
+			req.SetBasicAuth(username, value)
+			return nil
+		}}
+	case auth.Bearer != nil:
+		token := newSecret(auth.Bearer.Token, auth.Bearer.TokenFile)
+		return &authRoundTripper{next: next, authorize: func(req *http.Request) error {
+			value, err := token()
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Authorization", "Bearer "+value)
+			return nil
+		}}
+	default:
+		return next
+	}
+}
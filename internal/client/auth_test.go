@@ -0,0 +1,92 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func newServer(t *testing.T) (*httptest.Server, *string) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte(`[]`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &authorization
+}
+
+func writeSecretFile(t *testing.T, path, content string, modTime time.Time) {
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	t.Run("Should not send credentials without auth", func(t *testing.T) {
+		server, authorization := newServer(t)
+		client, err := New(config.Cluster{Timeout: time.Second})
+		assert.Nil(t, err)
+
+		_, err = client.Get(server.URL)
+		assert.Nil(t, err)
+		assert.Empty(t, *authorization)
+	})
+
+	t.Run("Should send basic auth credentials", func(t *testing.T) {
+		server, authorization := newServer(t)
+		client, err := New(config.Cluster{Auth: config.Auth{Basic: &config.BasicAuth{Username: "user", Password: "password"}}})
+		assert.Nil(t, err)
+
+		_, err = client.Get(server.URL)
+		assert.Nil(t, err)
+		assert.Equal(t, "Basic dXNlcjpwYXNzd29yZA==", *authorization)
+	})
+
+	t.Run("Should send a bearer token", func(t *testing.T) {
+		server, authorization := newServer(t)
+		client, err := New(config.Cluster{Auth: config.Auth{Bearer: &config.BearerAuth{Token: "token"}}})
+		assert.Nil(t, err)
+
+		_, err = client.Get(server.URL)
+		assert.Nil(t, err)
+		assert.Equal(t, "Bearer token", *authorization)
+	})
+
+	t.Run("Should re-read a rotated secret file", func(t *testing.T) {
+		server, authorization := newServer(t)
+		path := filepath.Join(t.TempDir(), "token")
+		writeSecretFile(t, path, "token1\n", time.Now().Add(-time.Minute))
+
+		client, err := New(config.Cluster{Auth: config.Auth{Bearer: &config.BearerAuth{TokenFile: path}}})
+		assert.Nil(t, err)
+
+		_, err = client.Get(server.URL)
+		assert.Nil(t, err)
+		assert.Equal(t, "Bearer token1", *authorization)
+
+		writeSecretFile(t, path, "token2\n", time.Now())
+
+		_, err = client.Get(server.URL)
+		assert.Nil(t, err)
+		assert.Equal(t, "Bearer token2", *authorization)
+	})
+
+	t.Run("Should fail the request when the secret file is missing", func(t *testing.T) {
+		server, _ := newServer(t)
+		client, err := New(config.Cluster{Auth: config.Auth{Basic: &config.BasicAuth{Username: "user", PasswordFile: filepath.Join(t.TempDir(), "missing")}}})
+		assert.Nil(t, err)
+
+		_, err = client.Get(server.URL)
+		assert.ErrorContains(t, err, "failed to read secret file")
+	})
+}
@@ -0,0 +1,22 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	applicationError "github.com/Ecube-Labs/kafka-connect-exporter/pkg/application-error"
+)
+
+// Build the http client used to call the kafka connect REST API of the given cluster
+func New(cluster config.Cluster) (*http.Client, error) {
+	transport, err := withTLS(http.DefaultTransport.(*http.Transport).Clone(), cluster.TLS)
+	if err != nil {
+		return nil, applicationError.New(http.StatusBadRequest, fmt.Sprintf("Invalid TLS config of cluster %s: %s", cluster.Name, err.Error()), "")
+	}
+
+	return &http.Client{
+		Transport: withAuth(transport, cluster.Auth),
+		Timeout:   cluster.Timeout,
+	}, nil
+}
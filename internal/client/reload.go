@@ -0,0 +1,60 @@
+package client
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+type fileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+// A value loaded from files, which is loaded again whenever one of the files changes on disk.
+// This picks up rotated secrets and certificates without a restart.
+type reloadable[T any] struct {
+	paths []string
+	load  func() (T, error)
+
+	mu     sync.Mutex
+	value  T
+	stats  []fileStat
+	loaded bool
+}
+
+func newReloadable[T any](load func() (T, error), paths ...string) *reloadable[T] {
+	return &reloadable[T]{paths: paths, load: load}
+}
+
+func (r *reloadable[T]) get() (T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := make([]fileStat, len(r.paths))
+	changed := !r.loaded
+	for i, path := range r.paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		stats[i] = fileStat{modTime: info.ModTime(), size: info.Size()}
+		if r.loaded && (!stats[i].modTime.Equal(r.stats[i].modTime) || stats[i].size != r.stats[i].size) {
+			changed = true
+		}
+	}
+	if !changed {
+		return r.value, nil
+	}
+
+	value, err := r.load()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	r.value = value
+	r.stats = stats
+	r.loaded = true
+	return value, nil
+}
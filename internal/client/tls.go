@@ -0,0 +1,143 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+)
+
+// how long the transport of a host is kept without requests, e.g. after the pod of the host was replaced
+const idleTransportTimeout = 10 * time.Minute
+
+// Configure TLS of the transport for a cluster.
+// Client certificates are loaded again on every handshake when they changed on disk. The CA bundle is reloaded
+// the same way, by verifying the server certificate in VerifyConnection instead of with fixed RootCAs.
+// Both are part of the TLS configuration of the transport, so that they also apply to connections through a proxy.
+func withTLS(transport *http.Transport, settings config.TLS) (http.RoundTripper, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         settings.ServerName,
+		InsecureSkipVerify: settings.InsecureSkipVerify,
+	}
+
+	if settings.CertFile != "" {
+		certificate := newReloadable(func() (*tls.Certificate, error) {
+			certificate, err := tls.LoadX509KeyPair(settings.CertFile, settings.KeyFile)
+			if err != nil {
+				return nil, err
+			}
+			return &certificate, nil
+		}, settings.CertFile, settings.KeyFile)
+		if _, err := certificate.get(); err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return certificate.get()
+		}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	if settings.CAFile == "" || settings.InsecureSkipVerify {
+		return transport, nil
+	}
+
+	roots := newReloadable(func() (*x509.CertPool, error) {
+		content, err := os.ReadFile(settings.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("no certificates found in %s", settings.CAFile)
+		}
+		return pool, nil
+	}, settings.CAFile)
+	if _, err := roots.get(); err != nil {
+		return nil, fmt.Errorf("failed to load CA bundle: %w", err)
+	}
+
+	// VerifyConnection does not know the host of the request, and the server name of the connection state is
+	// empty for IP addresses, so every host gets its own transport that verifies the certificate for the host
+	return &hostTransports{base: transport, transports: map[string]*hostTransport{}, configure: func(transport *http.Transport, host string) {
+		serverName := settings.ServerName
+		if serverName == "" {
+			serverName = host
+		}
+		hostConfig := tlsConfig.Clone()
+		// the default verification is replaced by the verification with the current CA bundle
+		hostConfig.InsecureSkipVerify = true
+		hostConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			pool, err := roots.get()
+			if err != nil {
+				return fmt.Errorf("failed to load CA bundle: %w", err)
+			}
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("no server certificate")
+			}
+
+			// a DNS name is checked against the DNS names of the certificate and an IP address against its IP addresses
+			options := x509.VerifyOptions{Roots: pool, DNSName: serverName, Intermediates: x509.NewCertPool()}
+			for _, intermediate := range state.PeerCertificates[1:] {
+				options.Intermediates.AddCert(intermediate)
+			}
+			_, err = state.PeerCertificates[0].Verify(options)
+			return err
+		}
+		transport.TLSClientConfig = hostConfig
+	}}, nil
+}
+
+// Sends the requests of every host through a transport of its own, configured for the host.
+// Transports of hosts without requests for idleTransportTimeout are dropped when another host is added.
+type hostTransports struct {
+	base      *http.Transport
+	configure func(transport *http.Transport, host string)
+
+	mu         sync.Mutex
+	transports map[string]*hostTransport
+}
+
+type hostTransport struct {
+	transport *http.Transport
+	lastUsed  time.Time
+}
+
+func (h *hostTransports) RoundTrip(req *http.Request) (*http.Response, error) {
+	return h.transport(req.URL.Hostname()).RoundTrip(req)
+}
+
+func (h *hostTransports) transport(host string) *http.Transport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := h.transports[host]
+	if !ok {
+		for name, idle := range h.transports {
+			if now.Sub(idle.lastUsed) > idleTransportTimeout {
+				idle.transport.CloseIdleConnections()
+				delete(h.transports, name)
+			}
+		}
+		entry = &hostTransport{transport: h.base.Clone()}
+		h.configure(entry.transport, host)
+		h.transports[host] = entry
+	}
+	entry.lastUsed = now
+	return entry.transport
+}
+
+func (h *hostTransports) CloseIdleConnections() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, entry := range h.transports {
+		entry.transport.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,307 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type certificateAuthority struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	pem         []byte
+}
+
+func newCertificateAuthority(t *testing.T) *certificateAuthority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &certificateAuthority{
+		certificate: certificate,
+		key:         key,
+		pem:         pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// Issue a certificate and return it as PEM encoded certificate and key
+func (ca *certificateAuthority) issue(t *testing.T, commonName string, dnsNames []string, ips []net.IP) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     dnsNames,
+		IPAddresses:  ips,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.certificate, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// the IP address of the test servers
+var loopback = []net.IP{net.ParseIP("127.0.0.1")}
+
+// Start a https server that responds with the common name of the client certificate, if any
+func newTLSServer(t *testing.T, ca *certificateAuthority, dnsNames []string, ips []net.IP, clientCAs *x509.CertPool) *httptest.Server {
+	certPEM, keyPEM := ca.issue(t, "server", dnsNames, ips)
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+		}
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{certificate}}
+	if clientCAs != nil {
+		server.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+		server.TLS.ClientCAs = clientCAs
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Start a proxy that tunnels CONNECT requests and counts them
+func newProxy(t *testing.T, tunnels *atomic.Int32) *httptest.Server {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		tunnels.Add(1)
+		w.WriteHeader(http.StatusOK)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		go func() {
+			defer conn.Close()
+			defer upstream.Close()
+			go io.Copy(upstream, conn)
+			io.Copy(conn, upstream)
+		}()
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy
+}
+
+func writeFile(t *testing.T, path string, content []byte, modTime time.Time) {
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func get(t *testing.T, client *http.Client, url string) (string, error) {
+	// every request uses a new connection, so that reloaded files are used for the handshake
+	client.CloseIdleConnections()
+	response, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	body := make([]byte, 64)
+	n, _ := response.Body.Read(body)
+	return string(body[:n]), nil
+}
+
+func TestTLS(t *testing.T) {
+	ca := newCertificateAuthority(t)
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	writeFile(t, caFile, ca.pem, time.Now())
+
+	t.Run("Should verify the server with the CA bundle", func(t *testing.T) {
+		server := newTLSServer(t, ca, nil, loopback, nil)
+
+		client, err := New(config.Cluster{TLS: config.TLS{CAFile: caFile}})
+		assert.Nil(t, err)
+
+		_, err = get(t, client, server.URL)
+		assert.Nil(t, err)
+	})
+
+	t.Run("Should verify the server with the CA bundle through a proxy", func(t *testing.T) {
+		server := newTLSServer(t, ca, nil, loopback, nil)
+		var tunnels atomic.Int32
+		proxy := newProxy(t, &tunnels)
+		proxyURL, _ := url.Parse(proxy.URL)
+
+		client, err := New(config.Cluster{TLS: config.TLS{CAFile: caFile}})
+		assert.Nil(t, err)
+		client.Transport.(*hostTransports).base.Proxy = http.ProxyURL(proxyURL)
+
+		_, err = get(t, client, server.URL)
+		assert.Nil(t, err)
+		assert.Equal(t, int32(1), tunnels.Load())
+
+		client, err = New(config.Cluster{TLS: config.TLS{CAFile: caFile, ServerName: "other.internal"}})
+		assert.Nil(t, err)
+		client.Transport.(*hostTransports).base.Proxy = http.ProxyURL(proxyURL)
+
+		_, err = get(t, client, server.URL)
+		assert.ErrorContains(t, err, "other.internal")
+	})
+
+	t.Run("Should reject a certificate of the CA without the IP address of the host", func(t *testing.T) {
+		server := newTLSServer(t, ca, []string{"connect.internal"}, nil, nil)
+
+		client, err := New(config.Cluster{TLS: config.TLS{CAFile: caFile}})
+		assert.Nil(t, err)
+
+		_, err = get(t, client, server.URL)
+		assert.ErrorContains(t, err, "127.0.0.1")
+
+		var tunnels atomic.Int32
+		proxy := newProxy(t, &tunnels)
+		proxyURL, _ := url.Parse(proxy.URL)
+		client.Transport.(*hostTransports).base.Proxy = http.ProxyURL(proxyURL)
+		client.Transport.(*hostTransports).transports = map[string]*hostTransport{}
+
+		_, err = get(t, client, server.URL)
+		assert.ErrorContains(t, err, "127.0.0.1")
+		assert.Equal(t, int32(1), tunnels.Load())
+	})
+
+	t.Run("Should reject a server signed by an unknown CA", func(t *testing.T) {
+		server := newTLSServer(t, ca, nil, loopback, nil)
+
+		client, err := New(config.Cluster{})
+		assert.Nil(t, err)
+
+		_, err = get(t, client, server.URL)
+		assert.ErrorContains(t, err, "certificate signed by unknown authority")
+	})
+
+	t.Run("Should skip verification when insecure_skip_verify is set", func(t *testing.T) {
+		server := newTLSServer(t, ca, nil, loopback, nil)
+
+		client, err := New(config.Cluster{TLS: config.TLS{InsecureSkipVerify: true}})
+		assert.Nil(t, err)
+
+		_, err = get(t, client, server.URL)
+		assert.Nil(t, err)
+	})
+
+	t.Run("Should verify the overridden server name", func(t *testing.T) {
+		server := newTLSServer(t, ca, []string{"connect.internal"}, loopback, nil)
+
+		client, err := New(config.Cluster{TLS: config.TLS{CAFile: caFile, ServerName: "connect.internal"}})
+		assert.Nil(t, err)
+		_, err = get(t, client, server.URL)
+		assert.Nil(t, err)
+
+		client, err = New(config.Cluster{TLS: config.TLS{CAFile: caFile, ServerName: "other.internal"}})
+		assert.Nil(t, err)
+		_, err = get(t, client, server.URL)
+		assert.ErrorContains(t, err, "other.internal")
+	})
+
+	t.Run("Should reload the CA bundle when it changes", func(t *testing.T) {
+		server := newTLSServer(t, ca, nil, loopback, nil)
+		otherCA := newCertificateAuthority(t)
+		rotatedCAFile := filepath.Join(t.TempDir(), "ca.pem")
+		writeFile(t, rotatedCAFile, otherCA.pem, time.Now().Add(-time.Minute))
+
+		client, err := New(config.Cluster{TLS: config.TLS{CAFile: rotatedCAFile}})
+		assert.Nil(t, err)
+		_, err = get(t, client, server.URL)
+		assert.ErrorContains(t, err, "certificate signed by unknown authority")
+
+		writeFile(t, rotatedCAFile, append(otherCA.pem, ca.pem...), time.Now())
+
+		_, err = get(t, client, server.URL)
+		assert.Nil(t, err)
+	})
+
+	t.Run("Should present a client certificate and reload it when it changes", func(t *testing.T) {
+		pool := x509.NewCertPool()
+		pool.AddCert(ca.certificate)
+		server := newTLSServer(t, ca, nil, loopback, pool)
+
+		certFile := filepath.Join(t.TempDir(), "client.pem")
+		keyFile := filepath.Join(t.TempDir(), "client-key.pem")
+		certPEM, keyPEM := ca.issue(t, "client1", nil, nil)
+		writeFile(t, certFile, certPEM, time.Now().Add(-time.Minute))
+		writeFile(t, keyFile, keyPEM, time.Now().Add(-time.Minute))
+
+		client, err := New(config.Cluster{TLS: config.TLS{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}})
+		assert.Nil(t, err)
+
+		commonName, err := get(t, client, server.URL)
+		assert.Nil(t, err)
+		assert.Equal(t, "client1", commonName)
+
+		certPEM, keyPEM = ca.issue(t, "client2", nil, nil)
+		writeFile(t, certFile, certPEM, time.Now())
+		writeFile(t, keyFile, keyPEM, time.Now())
+
+		commonName, err = get(t, client, server.URL)
+		assert.Nil(t, err)
+		assert.Equal(t, "client2", commonName)
+	})
+
+	t.Run("Should return an error for an invalid client certificate", func(t *testing.T) {
+		_, err := New(config.Cluster{Name: "prod", TLS: config.TLS{CertFile: caFile, KeyFile: caFile}})
+		assert.ErrorContains(t, err, "Invalid TLS config of cluster prod: failed to load client certificate")
+	})
+}
@@ -1,15 +1,22 @@
 package collector
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
 
 	applicationError "github.com/Ecube-Labs/kafka-connect-exporter/pkg/application-error"
+	"github.com/Ecube-Labs/kafka-connect-exporter/pkg/logger"
 )
 
+// how many requests about single connectors are sent to a host at once, e.g. to read their offsets or topics
+const ConnectorConcurrency = 8
+
 type Collector struct {
 	client *http.Client
 }
@@ -24,47 +31,334 @@ func New(client *http.Client) *Collector {
 func (c *Collector) GetConnectors(host string) ([]string, error) {
 	response, err := c.client.Get(fmt.Sprintf("%s/connectors", host))
 	if err != nil {
-		return nil, applicationError.New(http.StatusInternalServerError, err.Error(), "")
+		return nil, requestFailure(err)
 	}
 	defer response.Body.Close()
 
 	if response.StatusCode != http.StatusOK {
 		body, err := io.ReadAll(response.Body)
 		if err != nil {
-			return nil, applicationError.New(http.StatusInternalServerError, err.Error(), "")
+			return nil, requestFailure(err)
 		}
 		return nil, applicationError.New(response.StatusCode, fmt.Sprintf("Failed to get connectors. status: %d, body: %s", response.StatusCode, string(body)), "")
 	}
 
 	var connectors []string
 	if err := json.NewDecoder(response.Body).Decode(&connectors); err != nil {
-		return nil, applicationError.New(http.StatusInternalServerError, err.Error(), "")
+		return nil, decodeFailure(err)
 	}
 
 	return connectors, nil
 }
 
 // Retrieve the status of a kafka connect connector
-func (c *Collector) GetConnectorStatus(host string, connector string) (*connectorStatus, error) {
+func (c *Collector) GetConnectorStatus(host string, connector string) (*ConnectorStatus, error) {
 	encodedConnectorName := url.PathEscape(connector)
 	response, err := c.client.Get(fmt.Sprintf("%s/connectors/%s/status", host, encodedConnectorName))
 	if err != nil {
-		return nil, applicationError.New(http.StatusInternalServerError, err.Error(), "")
+		return nil, requestFailure(err)
 	}
 	defer response.Body.Close()
 
 	if response.StatusCode != http.StatusOK {
 		body, err := io.ReadAll(response.Body)
 		if err != nil {
-			return nil, applicationError.New(http.StatusInternalServerError, err.Error(), "")
+			return nil, requestFailure(err)
 		}
 		return nil, applicationError.New(response.StatusCode, fmt.Sprintf("Failed to get connector status. status: %d, body: %s", response.StatusCode, string(body)), "")
 	}
 
-	var status connectorStatus
+	var status ConnectorStatus
 	if err := json.NewDecoder(response.Body).Decode(&status); err != nil {
-		return nil, applicationError.New(http.StatusInternalServerError, err.Error(), "")
+		return nil, decodeFailure(err)
 	}
 
 	return &status, nil
 }
+
+// Retrieve the configuration of a kafka connect connector
+func (c *Collector) GetConnectorInfo(host string, connector string) (*ConnectorInfo, error) {
+	encodedConnectorName := url.PathEscape(connector)
+	response, err := c.client.Get(fmt.Sprintf("%s/connectors/%s", host, encodedConnectorName))
+	if err != nil {
+		return nil, requestFailure(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			return nil, requestFailure(err)
+		}
+		return nil, applicationError.New(response.StatusCode, fmt.Sprintf("Failed to get connector info. status: %d, body: %s", response.StatusCode, string(body)), "")
+	}
+
+	var info ConnectorInfo
+	if err := json.NewDecoder(response.Body).Decode(&info); err != nil {
+		return nil, decodeFailure(err)
+	}
+
+	return &info, nil
+}
+
+// Retrieve the version of the worker and the id of its kafka cluster from the root endpoint
+func (c *Collector) GetWorkerInfo(host string) (*WorkerInfo, error) {
+	response, err := c.client.Get(fmt.Sprintf("%s/", host))
+	if err != nil {
+		return nil, requestFailure(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			return nil, requestFailure(err)
+		}
+		return nil, applicationError.New(response.StatusCode, fmt.Sprintf("Failed to get worker info. status: %d, body: %s", response.StatusCode, string(body)), "")
+	}
+
+	var info WorkerInfo
+	if err := json.NewDecoder(response.Body).Decode(&info); err != nil {
+		return nil, decodeFailure(err)
+	}
+
+	return &info, nil
+}
+
+// Get the connector plugins installed on the given host
+func (c *Collector) GetConnectorPlugins(host string) ([]ConnectorPlugin, error) {
+	response, err := c.client.Get(fmt.Sprintf("%s/connector-plugins", host))
+	if err != nil {
+		return nil, requestFailure(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			return nil, requestFailure(err)
+		}
+		return nil, applicationError.New(response.StatusCode, fmt.Sprintf("Failed to get connector plugins. status: %d, body: %s", response.StatusCode, string(body)), "")
+	}
+
+	var plugins []ConnectorPlugin
+	if err := json.NewDecoder(response.Body).Decode(&plugins); err != nil {
+		return nil, decodeFailure(err)
+	}
+
+	return plugins, nil
+}
+
+// Retrieve the status and info of every connector on the given host that matches the filter in a single request,
+// along with the number of connectors excluded by the filter.
+// The expand query parameters are supported since Kafka 2.3. Older workers ignore them and respond with a plain
+// list of connector names, in which case the status of each matching connector is requested one by one.
+func (c *Collector) GetExpandedConnectors(host string, filter *Filter) (map[string]*ExpandedConnector, int, error) {
+	response, err := c.client.Get(fmt.Sprintf("%s/connectors?expand=status&expand=info", host))
+	if err != nil {
+		return nil, 0, requestFailure(err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, 0, requestFailure(err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, 0, applicationError.New(response.StatusCode, fmt.Sprintf("Failed to get connectors. status: %d, body: %s", response.StatusCode, string(body)), "")
+	}
+
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		var names []string
+		if err := json.Unmarshal(trimmed, &names); err != nil {
+			return nil, 0, decodeFailure(err)
+		}
+
+		var matching []string
+		for _, name := range names {
+			if filter.Match(name) {
+				matching = append(matching, name)
+			}
+		}
+		return c.getConnectorStatuses(host, matching), len(names) - len(matching), nil
+	}
+
+	connectors := map[string]*ExpandedConnector{}
+	if err := json.Unmarshal(body, &connectors); err != nil {
+		return nil, 0, decodeFailure(err)
+	}
+
+	filtered := 0
+	for name := range connectors {
+		if !filter.Match(name) {
+			delete(connectors, name)
+			filtered++
+		}
+	}
+	return connectors, filtered, nil
+}
+
+// Retrieve the offsets of a kafka connect connector.
+// The offsets endpoint is supported since Kafka 3.5 (KIP-875).
+func (c *Collector) GetConnectorOffsets(host string, connector string) (*ConnectorOffsets, error) {
+	encodedConnectorName := url.PathEscape(connector)
+	response, err := c.client.Get(fmt.Sprintf("%s/connectors/%s/offsets", host, encodedConnectorName))
+	if err != nil {
+		return nil, requestFailure(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			return nil, requestFailure(err)
+		}
+		return nil, applicationError.New(response.StatusCode, fmt.Sprintf("Failed to get connector offsets. status: %d, body: %s", response.StatusCode, string(body)), "")
+	}
+
+	var offsets ConnectorOffsets
+	if err := json.NewDecoder(response.Body).Decode(&offsets); err != nil {
+		return nil, decodeFailure(err)
+	}
+
+	return &offsets, nil
+}
+
+// Retrieve the topics that a kafka connect connector has used since its creation or the last reset of its topics.
+// The topics endpoint is supported since Kafka 2.5 (KIP-558).
+func (c *Collector) GetConnectorTopics(host string, connector string) ([]string, error) {
+	encodedConnectorName := url.PathEscape(connector)
+	response, err := c.client.Get(fmt.Sprintf("%s/connectors/%s/topics", host, encodedConnectorName))
+	if err != nil {
+		return nil, requestFailure(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			return nil, requestFailure(err)
+		}
+		return nil, applicationError.New(response.StatusCode, fmt.Sprintf("Failed to get connector topics. status: %d, body: %s", response.StatusCode, string(body)), "")
+	}
+
+	var topics map[string]ConnectorTopics
+	if err := json.NewDecoder(response.Body).Decode(&topics); err != nil {
+		return nil, decodeFailure(err)
+	}
+
+	return topics[connector].Topics, nil
+}
+
+// Reset the set of topics that a kafka connect connector has used
+func (c *Collector) ResetConnectorTopics(host string, connector string) error {
+	encodedConnectorName := url.PathEscape(connector)
+	request, err := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/connectors/%s/topics/reset", host, encodedConnectorName), nil)
+	if err != nil {
+		return applicationError.New(http.StatusBadRequest, err.Error(), "")
+	}
+	response, err := c.client.Do(request)
+	if err != nil {
+		return requestFailure(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			return requestFailure(err)
+		}
+		return applicationError.New(response.StatusCode, fmt.Sprintf("Failed to reset connector topics. status: %d, body: %s", response.StatusCode, string(body)), "")
+	}
+
+	return nil
+}
+
+// Restart the failed instances of a connector and its tasks.
+// The includeTasks and onlyFailed query parameters require Kafka 3.0, older workers only restart the connector.
+func (c *Collector) RestartConnector(host string, connector string) error {
+	encodedConnectorName := url.PathEscape(connector)
+	response, err := c.client.Post(fmt.Sprintf("%s/connectors/%s/restart?includeTasks=true&onlyFailed=true", host, encodedConnectorName), "application/json", nil)
+	if err != nil {
+		return requestFailure(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			return requestFailure(err)
+		}
+		return applicationError.New(response.StatusCode, fmt.Sprintf("Failed to restart connector. status: %d, body: %s", response.StatusCode, string(body)), "")
+	}
+
+	return nil
+}
+
+// Fallback for workers without support for the expand query parameters.
+// A connector whose status or info could not be retrieved is kept with a nil value, so that it is still counted.
+func (c *Collector) getConnectorStatuses(host string, names []string) map[string]*ExpandedConnector {
+	connectors, _ := ReadConnectors(names, func(name string) (*ExpandedConnector, error) {
+		status, err := c.GetConnectorStatus(host, name)
+		if err != nil {
+			logger.Log("error", applicationError.UnWrap(err).Stack)
+		}
+		info, err := c.GetConnectorInfo(host, name)
+		if err != nil {
+			logger.Log("error", applicationError.UnWrap(err).Stack)
+		}
+		return &ExpandedConnector{Status: status, Info: info}, nil
+	})
+	return connectors
+}
+
+// Call read for every connector with at most ConnectorConcurrency calls at once.
+// Returns the results of the successful calls by connector and the errors of the failed calls.
+func ReadConnectors[T any](names []string, read func(name string) (T, error)) (map[string]T, []error) {
+	results := make(map[string]T, len(names))
+	var errs []error
+	var mu sync.Mutex
+
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for range min(ConnectorConcurrency, len(names)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range queue {
+				result, err := read(name)
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				} else {
+					results[name] = result
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, name := range names {
+		queue <- name
+	}
+	close(queue)
+	wg.Wait()
+	return results, errs
+}
+
+// Find the version of the plugin of a connector class.
+// Like kafka connect, the class may also be given as an alias: the simple class name, with or without the "Connector" suffix.
+func PluginVersion(plugins []ConnectorPlugin, class string) string {
+	for _, plugin := range plugins {
+		if plugin.Class == class {
+			return plugin.Version
+		}
+	}
+	for _, plugin := range plugins {
+		simpleName := plugin.Class[strings.LastIndex(plugin.Class, ".")+1:]
+		if simpleName == class || strings.TrimSuffix(simpleName, "Connector") == class {
+			return plugin.Version
+		}
+	}
+	return ""
+}
@@ -2,9 +2,13 @@ package collector
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -64,13 +68,13 @@ func TestGetConnectorStatus(t *testing.T) {
 		roundTripper := &mockRoundTripper{
 			roundTripFunc: func(req *http.Request) (*http.Response, error) {
 				response := httptest.NewRecorder()
-				mockResponse := connectorStatus{
+				mockResponse := ConnectorStatus{
 					Name: "connector1",
 					Connector: struct {
 						State    string `json:"state"`
 						WorkerID string `json:"worker_id"`
 					}{State: "RUNNING", WorkerID: "123"},
-					Tasks: []connectorTaskStatus{
+					Tasks: []ConnectorTaskStatus{
 						{ID: 1, State: "RUNNING", WorkerID: "123", Trace: "trace1"},
 					},
 				}
@@ -90,11 +94,11 @@ func TestGetConnectorStatus(t *testing.T) {
 
 		status, err := collector.GetConnectorStatus("test", "connector1")
 		assert.Nil(t, err)
-		assert.Equal(t, &connectorStatus{Name: "connector1", Connector: struct {
+		assert.Equal(t, &ConnectorStatus{Name: "connector1", Connector: struct {
 			State    string `json:"state"`
 			WorkerID string `json:"worker_id"`
 		}{State: "RUNNING", WorkerID: "123"},
-			Tasks: []connectorTaskStatus{{ID: 1, State: "RUNNING", WorkerID: "123", Trace: "trace1"}}}, status) // Compare this snippet from internal/collector/types.go:
+			Tasks: []ConnectorTaskStatus{{ID: 1, State: "RUNNING", WorkerID: "123", Trace: "trace1"}}}, status) // Compare this snippet from internal/collector/types.go:
 	})
 	t.Run("Should return an error when the request fails", func(t *testing.T) {
 		roundTripper := &mockRoundTripper{
@@ -112,3 +116,368 @@ func TestGetConnectorStatus(t *testing.T) {
 		assert.Equal(t, `Failed to get connector status. status: 444, body: "Internal Server Error"`, err.Error())
 	})
 }
+
+func TestGetExpandedConnectors(t *testing.T) {
+	t.Run("Should return the status and info of connectors in a single request", func(t *testing.T) {
+		requestCount := 0
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				requestCount++
+				assert.Equal(t, "/connectors", req.URL.Path)
+				assert.Equal(t, []string{"status", "info"}, req.URL.Query()["expand"])
+
+				response := httptest.NewRecorder()
+				response.Write([]byte(`{
+					"connector1": {
+						"status": {"name": "connector1", "connector": {"state": "RUNNING", "worker_id": "123"}, "tasks": [{"id": 0, "state": "RUNNING", "worker_id": "123"}], "type": "sink"},
+						"info": {"name": "connector1", "config": {"connector.class": "FileStreamSink"}, "tasks": [{"connector": "connector1", "task": 0}], "type": "sink"}
+					}
+				}`))
+				return response.Result(), nil
+			},
+		}
+
+		collector := New(&http.Client{Transport: roundTripper})
+
+		connectors, _, err := collector.GetExpandedConnectors("http://test", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, requestCount)
+		assert.Len(t, connectors, 1)
+		assert.Equal(t, "RUNNING", connectors["connector1"].Status.Connector.State)
+		assert.Equal(t, "sink", connectors["connector1"].Status.Type)
+		assert.Equal(t, []ConnectorTaskStatus{{ID: 0, State: "RUNNING", WorkerID: "123"}}, connectors["connector1"].Status.Tasks)
+		assert.Equal(t, "FileStreamSink", connectors["connector1"].Info.Config["connector.class"])
+	})
+
+	t.Run("Should fall back to per connector status requests on workers without expand support", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				switch req.URL.Path {
+				case "/connectors":
+					response.Write([]byte(`["connector1", "connector2"]`))
+				case "/connectors/connector1/status":
+					response.Write([]byte(`{"name": "connector1", "connector": {"state": "PAUSED"}, "tasks": []}`))
+				default:
+					response.WriteHeader(http.StatusNotFound)
+				}
+				return response.Result(), nil
+			},
+		}
+
+		collector := New(&http.Client{Transport: roundTripper})
+
+		connectors, _, err := collector.GetExpandedConnectors("http://test", nil)
+		assert.Nil(t, err)
+		assert.Len(t, connectors, 2)
+		assert.Equal(t, "PAUSED", connectors["connector1"].Status.Connector.State)
+		assert.Nil(t, connectors["connector1"].Info)
+		assert.Nil(t, connectors["connector2"].Status)
+	})
+
+	t.Run("Should only return connectors matching the filter", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				response.Write([]byte(`{"orders-sink": {"status": {"name": "orders-sink"}}, "test-1": {"status": {"name": "test-1"}}}`))
+				return response.Result(), nil
+			},
+		}
+		collector := New(&http.Client{Transport: roundTripper})
+		filter, err := NewFilter(nil, []string{"test-.*"})
+		assert.Nil(t, err)
+
+		connectors, filtered, err := collector.GetExpandedConnectors("http://test", filter)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, filtered)
+		assert.Len(t, connectors, 1)
+		assert.Contains(t, connectors, "orders-sink")
+	})
+
+	t.Run("Should return an error when the request fails", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: (func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				response.WriteHeader(444)
+				response.Write([]byte(`"Internal Server Error"`))
+				return response.Result(), nil
+			})}
+		collector := New(&http.Client{Transport: roundTripper})
+
+		connectors, _, err := collector.GetExpandedConnectors("http://test", nil)
+		assert.Nil(t, connectors)
+		assert.NotNil(t, err)
+		assert.Equal(t, `Failed to get connectors. status: 444, body: "Internal Server Error"`, err.Error())
+	})
+}
+
+func TestGetConnectorInfo(t *testing.T) {
+	t.Run("Should return the info of a connector", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				assert.Equal(t, "/connectors/connector%201", req.URL.EscapedPath())
+				response := httptest.NewRecorder()
+				response.Write([]byte(`{"name": "connector 1", "config": {"connector.class": "FileStreamSink"}, "tasks": [{"connector": "connector 1", "task": 0}], "type": "sink"}`))
+				return response.Result(), nil
+			},
+		}
+		collector := New(&http.Client{Transport: roundTripper})
+
+		info, err := collector.GetConnectorInfo("http://test", "connector 1")
+		assert.Nil(t, err)
+		assert.Equal(t, "sink", info.Type)
+		assert.Equal(t, "FileStreamSink", info.Config["connector.class"])
+		assert.Len(t, info.Tasks, 1)
+	})
+
+	t.Run("Should return an error when the request fails", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				response.WriteHeader(444)
+				response.Write([]byte(`"Internal Server Error"`))
+				return response.Result(), nil
+			},
+		}
+		collector := New(&http.Client{Transport: roundTripper})
+
+		info, err := collector.GetConnectorInfo("http://test", "connector1")
+		assert.Nil(t, info)
+		assert.Equal(t, `Failed to get connector info. status: 444, body: "Internal Server Error"`, err.Error())
+	})
+}
+
+func TestGetConnectorOffsets(t *testing.T) {
+	t.Run("Should return the offsets of a connector", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				assert.Equal(t, "/connectors/connector%201/offsets", req.URL.EscapedPath())
+				response := httptest.NewRecorder()
+				response.Write([]byte(`{"offsets": [{"partition": {"kafka_topic": "orders", "kafka_partition": 2}, "offset": {"kafka_offset": 4}}]}`))
+				return response.Result(), nil
+			},
+		}
+		collector := New(&http.Client{Transport: roundTripper})
+
+		offsets, err := collector.GetConnectorOffsets("http://test", "connector 1")
+		assert.Nil(t, err)
+		assert.Equal(t, &ConnectorOffsets{Offsets: []ConnectorOffset{{
+			Partition: map[string]any{"kafka_topic": "orders", "kafka_partition": float64(2)},
+			Offset:    map[string]any{"kafka_offset": float64(4)},
+		}}}, offsets)
+	})
+
+	t.Run("Should return an error when the request fails", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				response.WriteHeader(http.StatusNotFound)
+				response.Write([]byte(`{"error_code": 404, "message": "HTTP 404 Not Found"}`))
+				return response.Result(), nil
+			},
+		}
+		collector := New(&http.Client{Transport: roundTripper})
+
+		offsets, err := collector.GetConnectorOffsets("http://test", "connector1")
+		assert.Nil(t, offsets)
+		assert.Equal(t, `Failed to get connector offsets. status: 404, body: {"error_code": 404, "message": "HTTP 404 Not Found"}`, err.Error())
+	})
+}
+
+func TestGetConnectorTopics(t *testing.T) {
+	t.Run("Should return the active topics of a connector", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				assert.Equal(t, "/connectors/connector%201/topics", req.URL.EscapedPath())
+				response := httptest.NewRecorder()
+				response.Write([]byte(`{"connector 1": {"topics": ["orders", "payments"]}}`))
+				return response.Result(), nil
+			},
+		}
+		collector := New(&http.Client{Transport: roundTripper})
+
+		topics, err := collector.GetConnectorTopics("http://test", "connector 1")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"orders", "payments"}, topics)
+	})
+
+	t.Run("Should return an error when the request fails", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				response.WriteHeader(444)
+				response.Write([]byte(`"Internal Server Error"`))
+				return response.Result(), nil
+			},
+		}
+		collector := New(&http.Client{Transport: roundTripper})
+
+		topics, err := collector.GetConnectorTopics("http://test", "connector1")
+		assert.Nil(t, topics)
+		assert.Equal(t, `Failed to get connector topics. status: 444, body: "Internal Server Error"`, err.Error())
+	})
+}
+
+func TestResetConnectorTopics(t *testing.T) {
+	t.Run("Should reset the active topics of a connector", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				assert.Equal(t, http.MethodPut, req.Method)
+				assert.Equal(t, "/connectors/connector1/topics/reset", req.URL.Path)
+				response := httptest.NewRecorder()
+				response.WriteHeader(http.StatusAccepted)
+				return response.Result(), nil
+			},
+		}
+		collector := New(&http.Client{Transport: roundTripper})
+
+		assert.Nil(t, collector.ResetConnectorTopics("http://test", "connector1"))
+	})
+
+	t.Run("Should return an error when the reset is forbidden", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				response.WriteHeader(http.StatusForbidden)
+				response.Write([]byte(`{"error_code": 403, "message": "Topic tracking reset is disabled."}`))
+				return response.Result(), nil
+			},
+		}
+		collector := New(&http.Client{Transport: roundTripper})
+
+		err := collector.ResetConnectorTopics("http://test", "connector1")
+		assert.Equal(t, `Failed to reset connector topics. status: 403, body: {"error_code": 403, "message": "Topic tracking reset is disabled."}`, err.Error())
+	})
+}
+
+func TestGetWorkerInfo(t *testing.T) {
+	t.Run("Should return the version of the worker and its kafka cluster id", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				assert.Equal(t, "/", req.URL.Path)
+				response := httptest.NewRecorder()
+				response.Write([]byte(`{"version": "3.7.0", "commit": "2ae524ed625438c5", "kafka_cluster_id": "I4ZmrWqfT2e-upky_4fdPA"}`))
+				return response.Result(), nil
+			},
+		}
+		collector := New(&http.Client{Transport: roundTripper})
+
+		info, err := collector.GetWorkerInfo("http://test")
+		assert.Nil(t, err)
+		assert.Equal(t, &WorkerInfo{Version: "3.7.0", Commit: "2ae524ed625438c5", KafkaClusterID: "I4ZmrWqfT2e-upky_4fdPA"}, info)
+	})
+
+	t.Run("Should return an error when the worker responds with an error", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				response.WriteHeader(http.StatusServiceUnavailable)
+				return response.Result(), nil
+			},
+		}
+		collector := New(&http.Client{Transport: roundTripper})
+
+		info, err := collector.GetWorkerInfo("http://test")
+		assert.Nil(t, info)
+		assert.Equal(t, "Failed to get worker info. status: 503, body: ", err.Error())
+	})
+}
+
+func TestGetConnectorPlugins(t *testing.T) {
+	t.Run("Should return the installed connector plugins", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				assert.Equal(t, "/connector-plugins", req.URL.Path)
+				response := httptest.NewRecorder()
+				response.Write([]byte(`[{"class": "org.apache.kafka.connect.file.FileStreamSinkConnector", "type": "sink", "version": "3.7.0"}]`))
+				return response.Result(), nil
+			},
+		}
+		collector := New(&http.Client{Transport: roundTripper})
+
+		plugins, err := collector.GetConnectorPlugins("http://test")
+		assert.Nil(t, err)
+		assert.Equal(t, []ConnectorPlugin{{Class: "org.apache.kafka.connect.file.FileStreamSinkConnector", Type: "sink", Version: "3.7.0"}}, plugins)
+	})
+}
+
+func TestPluginVersion(t *testing.T) {
+	plugins := []ConnectorPlugin{
+		{Class: "org.apache.kafka.connect.file.FileStreamSinkConnector", Version: "3.7.0"},
+		{Class: "io.confluent.connect.s3.S3SinkConnector", Version: "10.5.0"},
+	}
+
+	t.Run("Should find the version by class name and alias", func(t *testing.T) {
+		assert.Equal(t, "10.5.0", PluginVersion(plugins, "io.confluent.connect.s3.S3SinkConnector"))
+		assert.Equal(t, "3.7.0", PluginVersion(plugins, "FileStreamSinkConnector"))
+		assert.Equal(t, "3.7.0", PluginVersion(plugins, "FileStreamSink"))
+	})
+
+	t.Run("Should return an empty version for unknown classes", func(t *testing.T) {
+		assert.Equal(t, "", PluginVersion(plugins, "com.example.UnknownConnector"))
+	})
+}
+
+func TestRestartConnector(t *testing.T) {
+	t.Run("Should restart the failed instances of a connector and its tasks", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				assert.Equal(t, http.MethodPost, req.Method)
+				assert.Equal(t, "/connectors/connector1/restart", req.URL.Path)
+				assert.Equal(t, "includeTasks=true&onlyFailed=true", req.URL.RawQuery)
+				response := httptest.NewRecorder()
+				response.WriteHeader(http.StatusAccepted)
+				return response.Result(), nil
+			},
+		}
+		collector := New(&http.Client{Transport: roundTripper})
+
+		assert.Nil(t, collector.RestartConnector("http://test", "connector1"))
+	})
+
+	t.Run("Should return an error when the request fails", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				response.WriteHeader(http.StatusConflict)
+				response.Write([]byte(`{"error_code": 409, "message": "Cannot complete request momentarily due to stale configuration"}`))
+				return response.Result(), nil
+			},
+		}
+		collector := New(&http.Client{Transport: roundTripper})
+
+		err := collector.RestartConnector("http://test", "connector1")
+		assert.Equal(t, `Failed to restart connector. status: 409, body: {"error_code": 409, "message": "Cannot complete request momentarily due to stale configuration"}`, err.Error())
+	})
+}
+
+func TestReadConnectors(t *testing.T) {
+	t.Run("Should read every connector with at most ConnectorConcurrency reads at once", func(t *testing.T) {
+		var running, maxRunning atomic.Int32
+		names := make([]string, 3*ConnectorConcurrency)
+		for i := range names {
+			names[i] = fmt.Sprintf("connector%d", i)
+		}
+
+		results, errs := ReadConnectors(names, func(name string) (string, error) {
+			current := running.Add(1)
+			defer running.Add(-1)
+			for {
+				previous := maxRunning.Load()
+				if current <= previous || maxRunning.CompareAndSwap(previous, current) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			if name == "connector0" {
+				return "", errors.New("failed")
+			}
+			return name, nil
+		})
+
+		assert.Len(t, results, len(names)-1)
+		assert.Equal(t, "connector1", results["connector1"])
+		assert.Len(t, errs, 1)
+		assert.LessOrEqual(t, maxRunning.Load(), int32(ConnectorConcurrency))
+	})
+}
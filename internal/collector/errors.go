@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+
+	applicationError "github.com/Ecube-Labs/kafka-connect-exporter/pkg/application-error"
+)
+
+// Kinds of failures when talking to the kafka connect REST API
+const (
+	FailureKindDial       = "dial"
+	FailureKindTLS        = "tls"
+	FailureKindConnection = "connection"
+	FailureKindTimeout    = "timeout"
+	FailureKindStatus     = "status"
+	FailureKindDecode     = "decode"
+)
+
+var FailureKinds = []string{FailureKindDial, FailureKindTLS, FailureKindConnection, FailureKindTimeout, FailureKindStatus, FailureKindDecode}
+
+// An application error with the kind of failure, set where the request failed. The kind is not derived from
+// the status code, because kafka connect or a proxy in front of it may respond with any status, e.g. 502 or 503.
+type failure struct {
+	kind string
+	err  error
+}
+
+func (f *failure) Error() string {
+	return f.err.Error()
+}
+
+func (f *failure) Unwrap() error {
+	return f.err
+}
+
+// A request that could not be completed: a timeout, a failure to connect, a failed TLS handshake or
+// any other transport error, e.g. a connection reset after it was established
+func requestFailure(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return &failure{kind: FailureKindTimeout, err: applicationError.New(http.StatusGatewayTimeout, err.Error(), "")}
+	}
+	if isTLSError(err) {
+		return &failure{kind: FailureKindTLS, err: applicationError.New(http.StatusBadGateway, err.Error(), "")}
+	}
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) || (errors.As(err, &opErr) && opErr.Op == "dial") {
+		return &failure{kind: FailureKindDial, err: applicationError.New(http.StatusServiceUnavailable, err.Error(), "")}
+	}
+	return &failure{kind: FailureKindConnection, err: applicationError.New(http.StatusBadGateway, err.Error(), "")}
+}
+
+// Whether the TLS handshake failed, e.g. on an untrusted certificate or an alert of the server
+func isTLSError(err error) bool {
+	var recordErr tls.RecordHeaderError
+	var verificationErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var opErr *net.OpError
+	return errors.As(err, &recordErr) || errors.As(err, &verificationErr) || errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) ||
+		// alerts sent by the server during the handshake
+		(errors.As(err, &opErr) && opErr.Op == "remote error")
+}
+
+// A response that could not be decoded
+func decodeFailure(err error) error {
+	return &failure{kind: FailureKindDecode, err: applicationError.New(http.StatusBadGateway, err.Error(), "")}
+}
+
+// The kind of failure of an error returned by the collector.
+// Any error without a kind, e.g. a non 2xx response of kafka connect, is a status failure.
+func FailureKind(err error) string {
+	var f *failure
+	if errors.As(err, &f) {
+		return f.kind
+	}
+	return FailureKindStatus
+}
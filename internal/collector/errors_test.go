@@ -0,0 +1,111 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	applicationError "github.com/Ecube-Labs/kafka-connect-exporter/pkg/application-error"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailureKind(t *testing.T) {
+	t.Run("Should classify a refused connection as a dial failure", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+			},
+		}
+		collector := New(&http.Client{Transport: roundTripper})
+
+		_, _, err := collector.GetExpandedConnectors("http://test", nil)
+		assert.Equal(t, FailureKindDial, FailureKind(err))
+	})
+
+	t.Run("Should classify a connection reset after it was established as a connection failure", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				return nil, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+			},
+		}
+		collector := New(&http.Client{Transport: roundTripper})
+
+		_, _, err := collector.GetExpandedConnectors("http://test", nil)
+		assert.Equal(t, FailureKindConnection, FailureKind(err))
+	})
+
+	t.Run("Should classify an untrusted certificate as a tls failure", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+		collector := New(&http.Client{})
+
+		_, _, err := collector.GetExpandedConnectors(server.URL, nil)
+		assert.Equal(t, FailureKindTLS, FailureKind(err))
+	})
+
+	t.Run("Should classify a client timeout as a timeout failure", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				<-req.Context().Done()
+				return nil, context.DeadlineExceeded
+			},
+		}
+		collector := New(&http.Client{Transport: roundTripper, Timeout: time.Millisecond})
+
+		_, _, err := collector.GetExpandedConnectors("http://test", nil)
+		assert.Equal(t, FailureKindTimeout, FailureKind(err))
+	})
+
+	t.Run("Should classify a non 2xx response as a status failure", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				response.WriteHeader(http.StatusInternalServerError)
+				return response.Result(), nil
+			},
+		}
+		collector := New(&http.Client{Transport: roundTripper})
+
+		_, _, err := collector.GetExpandedConnectors("http://test", nil)
+		assert.Equal(t, FailureKindStatus, FailureKind(err))
+	})
+
+	t.Run("Should classify gateway errors returned by kafka connect or a proxy as status failures", func(t *testing.T) {
+		for _, status := range []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout} {
+			roundTripper := &mockRoundTripper{
+				roundTripFunc: func(req *http.Request) (*http.Response, error) {
+					response := httptest.NewRecorder()
+					response.WriteHeader(status)
+					return response.Result(), nil
+				},
+			}
+			collector := New(&http.Client{Transport: roundTripper})
+
+			_, _, err := collector.GetExpandedConnectors("http://test", nil)
+			assert.Equal(t, FailureKindStatus, FailureKind(err), "status %d", status)
+		}
+	})
+
+	t.Run("Should classify an invalid body as a decode failure", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				response.Write([]byte(`<html>`))
+				return response.Result(), nil
+			},
+		}
+		collector := New(&http.Client{Transport: roundTripper})
+
+		_, _, err := collector.GetExpandedConnectors("http://test", nil)
+		assert.Equal(t, FailureKindDecode, FailureKind(err))
+	})
+
+	t.Run("Should classify errors that are not application errors as status failures", func(t *testing.T) {
+		assert.Equal(t, FailureKindStatus, FailureKind(applicationError.Wrap(errors.New("unknown"))))
+	})
+}
@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"regexp"
+)
+
+// Decides which connectors are collected by their name.
+// A connector is collected when it matches any include pattern, or there are none, and no exclude pattern.
+// Patterns are anchored, so they have to match the whole name.
+type Filter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+func NewFilter(include, exclude []string) (*Filter, error) {
+	filter := &Filter{}
+	for _, pattern := range include {
+		re, err := compilePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		filter.include = append(filter.include, re)
+	}
+	for _, pattern := range exclude {
+		re, err := compilePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		filter.exclude = append(filter.exclude, re)
+	}
+	return filter, nil
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// A nil filter matches every connector
+func (f *Filter) Match(connector string) bool {
+	if f == nil {
+		return true
+	}
+
+	included := len(f.include) == 0
+	for _, re := range f.include {
+		if re.MatchString(connector) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+
+	for _, re := range f.exclude {
+		if re.MatchString(connector) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter(t *testing.T) {
+	t.Run("Should match every connector without patterns", func(t *testing.T) {
+		filter, err := NewFilter(nil, nil)
+		assert.Nil(t, err)
+		assert.True(t, filter.Match("connector1"))
+
+		var nilFilter *Filter
+		assert.True(t, nilFilter.Match("connector1"))
+	})
+
+	t.Run("Should only match included connectors that are not excluded", func(t *testing.T) {
+		filter, err := NewFilter([]string{"orders-.*", "payments-.*"}, []string{".*-test"})
+		assert.Nil(t, err)
+
+		assert.True(t, filter.Match("orders-sink"))
+		assert.True(t, filter.Match("payments-source"))
+		assert.False(t, filter.Match("orders-test"))
+		assert.False(t, filter.Match("inventory-sink"))
+	})
+
+	t.Run("Should match the whole connector name", func(t *testing.T) {
+		filter, err := NewFilter([]string{"orders"}, nil)
+		assert.Nil(t, err)
+
+		assert.True(t, filter.Match("orders"))
+		assert.False(t, filter.Match("orders-sink"))
+	})
+
+	t.Run("Should return an error for invalid patterns", func(t *testing.T) {
+		_, err := NewFilter(nil, []string{"("})
+		assert.NotNil(t, err)
+	})
+}
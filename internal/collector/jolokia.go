@@ -0,0 +1,182 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	applicationError "github.com/Ecube-Labs/kafka-connect-exporter/pkg/application-error"
+)
+
+type jolokiaRequest struct {
+	Type  string `json:"type"`
+	MBean string `json:"mbean"`
+}
+
+type jolokiaResponse struct {
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+	// the attributes of every MBean matching the pattern of the request by MBean name,
+	// or the attributes of the MBean itself when the request is an MBean name without a pattern
+	Value json.RawMessage `json:"value"`
+}
+
+// Read the MBeans matching the patterns from the Jolokia agent at the given url in a single bulk request.
+// Only numeric attributes are returned. A pattern that matches no MBean, e.g. sink metrics on a worker
+// with only source connectors, is not an error.
+// An attribute is returned once per connector and task, from the first MBean in the order of the patterns
+// and names, e.g. when patterns overlap or MBeans only differ by another key like client-id.
+func (c *Collector) GetJMXMetrics(jolokiaURL string, mbeans []string) ([]JMXMetric, error) {
+	requests := make([]jolokiaRequest, len(mbeans))
+	for i, mbean := range mbeans {
+		requests[i] = jolokiaRequest{Type: "read", MBean: mbean}
+	}
+	body, err := json.Marshal(requests)
+	if err != nil {
+		return nil, applicationError.New(http.StatusInternalServerError, err.Error(), "")
+	}
+
+	response, err := c.client.Post(jolokiaURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, requestFailure(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			return nil, requestFailure(err)
+		}
+		return nil, applicationError.New(response.StatusCode, fmt.Sprintf("Failed to read MBeans. status: %d, body: %s", response.StatusCode, string(body)), "")
+	}
+
+	var responses []jolokiaResponse
+	if err := json.NewDecoder(response.Body).Decode(&responses); err != nil {
+		return nil, decodeFailure(err)
+	}
+	// the responses of a bulk request are in the order of the requests
+	if len(responses) != len(mbeans) {
+		return nil, applicationError.New(http.StatusBadGateway, fmt.Sprintf("Failed to read MBeans. expected %d responses, got %d", len(mbeans), len(responses)), "")
+	}
+
+	var metrics []JMXMetric
+	seen := map[jmxKey]bool{}
+	for i, response := range responses {
+		if response.Status == http.StatusNotFound {
+			continue
+		}
+		if response.Status != http.StatusOK {
+			return nil, applicationError.New(http.StatusBadGateway, fmt.Sprintf("Failed to read MBeans. status: %d, error: %s", response.Status, response.Error), "")
+		}
+		values, err := mbeanValues(mbeans[i], response.Value)
+		if err != nil {
+			return nil, decodeFailure(err)
+		}
+		names := slices.Sorted(maps.Keys(values))
+		for _, name := range names {
+			properties := parseMBeanName(name)
+			if properties["type"] == "" {
+				continue
+			}
+			for attribute, value := range values[name] {
+				// strings, e.g. the class of a connector, and NaN, which jolokia serializes as a string, are skipped
+				number, ok := value.(float64)
+				if !ok {
+					continue
+				}
+				metric := JMXMetric{
+					Type:      properties["type"],
+					Attribute: attribute,
+					Connector: properties["connector"],
+					Task:      properties["task"],
+					Value:     number,
+				}
+				key := jmxKey{name: metric.Name(), connector: metric.Connector, task: metric.Task}
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				metrics = append(metrics, metric)
+			}
+		}
+	}
+	return metrics, nil
+}
+
+// The series of an attribute, which must be unique per host
+type jmxKey struct {
+	name      string
+	connector string
+	task      string
+}
+
+// The attributes by MBean name of the value of a read. Jolokia returns the attributes by MBean name for a pattern,
+// and the attributes directly for the name of a single MBean.
+func mbeanValues(mbean string, value json.RawMessage) (map[string]map[string]any, error) {
+	if strings.ContainsAny(mbean, "*?") {
+		var values map[string]map[string]any
+		if err := json.Unmarshal(value, &values); err != nil {
+			return nil, err
+		}
+		return values, nil
+	}
+	var attributes map[string]any
+	if err := json.Unmarshal(value, &attributes); err != nil {
+		return nil, err
+	}
+	return map[string]map[string]any{mbean: attributes}, nil
+}
+
+// The key properties of an MBean name, e.g. kafka.connect:type=source-task-metrics,connector=my-source,task=0.
+// Values are unquoted, because connector names with special characters are quoted by kafka connect.
+func parseMBeanName(name string) map[string]string {
+	properties := map[string]string{}
+	_, list, ok := strings.Cut(name, ":")
+	if !ok {
+		return properties
+	}
+
+	for len(list) > 0 {
+		key, rest, ok := strings.Cut(list, "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			value, rest = unquote(rest)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		properties[key] = value
+		list = strings.TrimPrefix(rest, ",")
+	}
+	return properties
+}
+
+// Split a quoted value of an MBean name from the rest of the name
+func unquote(quoted string) (string, string) {
+	var value strings.Builder
+	for i := 1; i < len(quoted); i++ {
+		switch quoted[i] {
+		case '\\':
+			if i+1 < len(quoted) {
+				i++
+				if quoted[i] == 'n' {
+					value.WriteByte('\n')
+				} else {
+					value.WriteByte(quoted[i])
+				}
+			}
+		case '"':
+			return value.String(), quoted[i+1:]
+		default:
+			value.WriteByte(quoted[i])
+		}
+	}
+	return value.String(), ""
+}
@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A stub of a Jolokia agent answering bulk read requests from the given MBeans, by pattern or by MBean name
+func newJolokiaServer(t *testing.T, mbeans map[string]map[string]any) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/jolokia", r.URL.Path)
+
+		var requests []jolokiaRequest
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var responses []map[string]any
+		for _, request := range requests {
+			switch request.MBean {
+			case "kafka.connect:type=source-task-metrics,*":
+				responses = append(responses, map[string]any{"status": 200, "request": request, "value": mbeans})
+			case "kafka.connect:type=broken,*":
+				responses = append(responses, map[string]any{"status": 500, "request": request, "error": "java.lang.IllegalStateException"})
+			default:
+				if attributes, ok := mbeans[request.MBean]; ok {
+					responses = append(responses, map[string]any{"status": 200, "request": request, "value": attributes})
+					continue
+				}
+				responses = append(responses, map[string]any{"status": 404, "request": request, "error": "javax.management.InstanceNotFoundException"})
+			}
+		}
+		json.NewEncoder(w).Encode(responses)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGetJMXMetrics(t *testing.T) {
+	t.Run("Should return the numeric attributes of the MBeans with their connector and task", func(t *testing.T) {
+		server := newJolokiaServer(t, map[string]map[string]any{
+			"kafka.connect:connector=my-source,task=0,type=source-task-metrics": {
+				"source-record-poll-rate": 12.5,
+				"poll-batch-avg-time-ms":  "NaN",
+			},
+			`kafka.connect:connector="my,source",task=1,type=source-task-metrics`: {
+				"source-record-poll-rate": 3.0,
+			},
+		})
+
+		collector := New(http.DefaultClient)
+		metrics, err := collector.GetJMXMetrics(server.URL+"/jolokia", []string{"kafka.connect:type=source-task-metrics,*", "kafka.connect:type=sink-task-metrics,*"})
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, []JMXMetric{
+			{Type: "source-task-metrics", Attribute: "source-record-poll-rate", Connector: "my-source", Task: "0", Value: 12.5},
+			{Type: "source-task-metrics", Attribute: "source-record-poll-rate", Connector: "my,source", Task: "1", Value: 3},
+		}, metrics)
+	})
+
+	t.Run("Should return the attributes of an MBean read by its name", func(t *testing.T) {
+		server := newJolokiaServer(t, map[string]map[string]any{
+			"kafka.connect:type=connect-worker-metrics": {"connector-count": 2.0},
+		})
+
+		collector := New(http.DefaultClient)
+		metrics, err := collector.GetJMXMetrics(server.URL+"/jolokia", []string{"kafka.connect:type=connect-worker-metrics"})
+		assert.Nil(t, err)
+		assert.Equal(t, []JMXMetric{{Type: "connect-worker-metrics", Attribute: "connector-count", Value: 2}}, metrics)
+	})
+
+	t.Run("Should return an attribute once per connector and task", func(t *testing.T) {
+		server := newJolokiaServer(t, map[string]map[string]any{
+			"kafka.connect:client-id=b,connector=my-source,task=0,type=source-task-metrics": {"source-record-poll-rate": 2.0},
+			"kafka.connect:client-id=a,connector=my-source,task=0,type=source-task-metrics": {"source-record-poll-rate": 1.0},
+		})
+
+		collector := New(http.DefaultClient)
+		// overlapping patterns and MBeans that only differ by the client-id
+		metrics, err := collector.GetJMXMetrics(server.URL+"/jolokia", []string{
+			"kafka.connect:type=source-task-metrics,*",
+			"kafka.connect:client-id=b,connector=my-source,task=0,type=source-task-metrics",
+			"kafka.connect:type=source-task-metrics,*",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []JMXMetric{{Type: "source-task-metrics", Attribute: "source-record-poll-rate", Connector: "my-source", Task: "0", Value: 1}}, metrics)
+	})
+
+	t.Run("Should return an error when an MBean cannot be read", func(t *testing.T) {
+		server := newJolokiaServer(t, nil)
+
+		collector := New(http.DefaultClient)
+		metrics, err := collector.GetJMXMetrics(server.URL+"/jolokia", []string{"kafka.connect:type=broken,*"})
+		assert.Nil(t, metrics)
+		assert.Equal(t, "Failed to read MBeans. status: 500, error: java.lang.IllegalStateException", err.Error())
+	})
+
+	t.Run("Should return an error when the agent is not reachable", func(t *testing.T) {
+		server := newJolokiaServer(t, nil)
+		server.Close()
+
+		collector := New(http.DefaultClient)
+		_, err := collector.GetJMXMetrics(server.URL+"/jolokia", []string{"kafka.connect:type=source-task-metrics,*"})
+		assert.Equal(t, "dial", FailureKind(err))
+	})
+}
+
+func TestParseMBeanName(t *testing.T) {
+	t.Run("Should parse the key properties of an MBean name", func(t *testing.T) {
+		assert.Equal(t, map[string]string{"type": "connect-worker-metrics"}, parseMBeanName("kafka.connect:type=connect-worker-metrics"))
+		assert.Equal(t, map[string]string{"type": "sink-task-metrics", "connector": "a=b,\"c\"", "task": "2"}, parseMBeanName(`kafka.connect:type=sink-task-metrics,connector="a=b,\"c\"",task=2`))
+		assert.Equal(t, map[string]string{}, parseMBeanName("invalid"))
+	})
+}
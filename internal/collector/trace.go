@@ -0,0 +1,41 @@
+package collector
+
+import (
+	"regexp"
+	"strings"
+)
+
+const unknownException = "unknown"
+
+var (
+	// fully qualified java class name, possibly of a nested class
+	exceptionClassPattern = regexp.MustCompile(`^[A-Za-z_$][\w$]*(\.[A-Za-z_$][\w$]*)+$`)
+	maxExceptionClassLen  = 256
+)
+
+// Extract the class of the root cause from a java stack trace, which is the last "Caused by:" exception,
+// or the first exception when there is no cause.
+// Anything that does not look like a class name is reported as "unknown", so that the result can be used as a metric label.
+func RootException(trace string) string {
+	if trace == "" {
+		return unknownException
+	}
+
+	root := ""
+	for i, line := range strings.Split(trace, "\n") {
+		line = strings.TrimSpace(line)
+		if i == 0 {
+			root = line
+		}
+		if cause, ok := strings.CutPrefix(line, "Caused by:"); ok {
+			root = strings.TrimSpace(cause)
+		}
+	}
+
+	class, _, _ := strings.Cut(root, ":")
+	class = strings.TrimSpace(class)
+	if len(class) > maxExceptionClassLen || !exceptionClassPattern.MatchString(class) {
+		return unknownException
+	}
+	return class
+}
@@ -0,0 +1,37 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRootException(t *testing.T) {
+	t.Run("Should return the class of the last cause", func(t *testing.T) {
+		trace := "org.apache.kafka.connect.errors.ConnectException: Exiting WorkerSinkTask due to unrecoverable exception.\n" +
+			"\tat org.apache.kafka.connect.runtime.WorkerSinkTask.deliverMessages(WorkerSinkTask.java:614)\n" +
+			"\tat org.apache.kafka.connect.runtime.WorkerTask.run(WorkerTask.java:189)\n" +
+			"Caused by: org.apache.kafka.connect.errors.RetriableException: Failed to write\n" +
+			"\tat io.confluent.connect.jdbc.sink.JdbcSinkTask.put(JdbcSinkTask.java:116)\n" +
+			"\t... 10 more\n" +
+			"Caused by: org.apache.kafka.common.errors.TimeoutException: Timeout expired after 60000ms\n" +
+			"\t... 12 more\n"
+
+		assert.Equal(t, "org.apache.kafka.common.errors.TimeoutException", RootException(trace))
+	})
+
+	t.Run("Should return the class of the exception without causes", func(t *testing.T) {
+		trace := "java.lang.NullPointerException\n\tat com.example.MyTask.poll(MyTask.java:42)\n"
+		assert.Equal(t, "java.lang.NullPointerException", RootException(trace))
+	})
+
+	t.Run("Should return the class of a nested exception class", func(t *testing.T) {
+		assert.Equal(t, "com.example.Outer$InnerException", RootException("com.example.Outer$InnerException: failed"))
+	})
+
+	t.Run("Should return unknown for traces without a class name", func(t *testing.T) {
+		assert.Equal(t, "unknown", RootException(""))
+		assert.Equal(t, "unknown", RootException("Task failed for an unknown reason"))
+		assert.Equal(t, "unknown", RootException("Caused by: connection reset by peer"))
+	})
+}
@@ -1,15 +1,21 @@
 package collector
 
-type connectorStatus struct {
+import (
+	"regexp"
+	"strconv"
+)
+
+type ConnectorStatus struct {
 	Name      string `json:"name"`
+	Type      string `json:"type,omitempty"`
 	Connector struct {
 		State    string `json:"state"`
 		WorkerID string `json:"worker_id"`
 	} `json:"connector"`
-	Tasks []connectorTaskStatus `json:"tasks"`
+	Tasks []ConnectorTaskStatus `json:"tasks"`
 }
 
-type connectorTaskStatus struct {
+type ConnectorTaskStatus struct {
 	ID       int    `json:"id"`
 	State    string `json:"state"`
 	WorkerID string `json:"worker_id"`
@@ -24,3 +30,88 @@ type ConnectorStatusMetric struct {
 	FailedTaskCount     int
 	TotalTaskCount      int
 }
+
+type ConnectorInfo struct {
+	Name   string            `json:"name"`
+	Config map[string]string `json:"config"`
+	Tasks  []struct {
+		Connector string `json:"connector"`
+		Task      int    `json:"task"`
+	} `json:"tasks"`
+	Type string `json:"type"`
+}
+
+type ExpandedConnector struct {
+	Status *ConnectorStatus `json:"status"`
+	Info   *ConnectorInfo   `json:"info"`
+}
+
+// The type of the connector, e.g. sink or source. The type is only reported by kafka connect 2.1 and later.
+func (c *ExpandedConnector) Type() string {
+	if c.Info != nil && c.Info.Type != "" {
+		return c.Info.Type
+	}
+	if c.Status != nil && c.Status.Type != "" {
+		return c.Status.Type
+	}
+	return "unknown"
+}
+
+type ConnectorOffsets struct {
+	Offsets []ConnectorOffset `json:"offsets"`
+}
+
+// The offset of a partition. Sink connectors report a kafka_topic and kafka_partition with a kafka_offset,
+// source connectors any partition and offset of their source system.
+type ConnectorOffset struct {
+	Partition map[string]any `json:"partition"`
+	Offset    map[string]any `json:"offset"`
+}
+
+// The topic, partition and offset of the offset of a sink connector
+func (o ConnectorOffset) Sink() (string, string, float64, bool) {
+	topic, topicOk := o.Partition["kafka_topic"].(string)
+	partition, partitionOk := o.Partition["kafka_partition"].(float64)
+	value, valueOk := o.Offset["kafka_offset"].(float64)
+	if !topicOk || !partitionOk || !valueOk {
+		return "", "", 0, false
+	}
+	return topic, strconv.Itoa(int(partition)), value, true
+}
+
+type ConnectorTopics struct {
+	Topics []string `json:"topics"`
+}
+
+// The worker answering the root endpoint and the kafka cluster it is connected to
+type WorkerInfo struct {
+	Version        string `json:"version"`
+	Commit         string `json:"commit"`
+	KafkaClusterID string `json:"kafka_cluster_id"`
+}
+
+type ConnectorPlugin struct {
+	Class   string `json:"class"`
+	Type    string `json:"type"`
+	Version string `json:"version"`
+}
+
+// A numeric attribute of a kafka.connect MBean, e.g. the source-record-poll-rate of a source task
+type JMXMetric struct {
+	// the type of the MBean, e.g. source-task-metrics
+	Type      string
+	Attribute string
+	// empty for MBeans of the worker
+	Connector string
+	Task      string
+	Value     float64
+}
+
+// characters that are not allowed in metric names
+var metricNamePattern = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// The name of the metric of the attribute, e.g. kafka_connect_source_task_metrics_source_record_poll_rate
+// for the source-record-poll-rate of the source-task-metrics MBeans
+func (m JMXMetric) Name() string {
+	return "kafka_connect_" + metricNamePattern.ReplaceAllString(m.Type, "_") + "_" + metricNamePattern.ReplaceAllString(m.Attribute, "_")
+}
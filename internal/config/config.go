@@ -1,8 +1,322 @@
 package config
 
 import (
+	"bytes"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/states"
+	applicationError "github.com/Ecube-Labs/kafka-connect-exporter/pkg/application-error"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+type Config struct {
+	Port                string        `yaml:"port"`
+	MetricsEndpoint     string        `yaml:"metrics_endpoint"`
+	HealthCheckEndpoint string        `yaml:"health_check_endpoint"`
+	ProbeEndpoint       string        `yaml:"probe_endpoint"`
+	PollInterval        time.Duration `yaml:"poll_interval"`
+	Clusters            []Cluster     `yaml:"clusters"`
+	Remediation         Remediation   `yaml:"remediation"`
+	Notifications       Notifications `yaml:"notifications"`
+	// outputs of the metrics, prometheus and/or otlp
+	Exporters []string `yaml:"exporters"`
+	OTLP      OTLP     `yaml:"otlp"`
+	// settings of the targets of the probe endpoint by name
+	Modules map[string]Module `yaml:"modules"`
+	Strimzi Strimzi           `yaml:"strimzi"`
+	DNS     DNS               `yaml:"dns"`
+	// whether the default module was added by Load, e.g. for strimzi, instead of being configured
+	implicitDefaultModule bool
+}
+
+// Resolution of the dns+srv:// and dns+a:// urls of clusters
+type DNS struct {
+	// address of the DNS server as host:port, the resolver of the system when empty
+	Resolver        string        `yaml:"resolver"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+// A kafka connect cluster, reachable through one or more REST API urls
+type Cluster struct {
+	Name    string            `yaml:"name"`
+	URLs    []string          `yaml:"urls"`
+	Timeout time.Duration     `yaml:"timeout"`
+	Labels  map[string]string `yaml:"labels"`
+	Auth    Auth              `yaml:"auth"`
+	TLS     TLS               `yaml:"tls"`
+	Filters Filters           `yaml:"filters"`
+	// discover the urls of the workers in kubernetes, in addition to the static urls
+	Kubernetes *KubernetesDiscovery `yaml:"kubernetes"`
+	// read the urls of the workers from files, in addition to the static urls
+	FileSD *FileDiscovery `yaml:"file_sd"`
+	// read the metrics of the MBeans of every worker with Jolokia
+	Jolokia *Jolokia `yaml:"jolokia"`
+	// read the offsets of every connector, requires kafka connect 3.5
+	Offsets *Offsets `yaml:"offsets"`
+	// read the active topics of every connector, requires kafka connect 2.5
+	Topics *Topics `yaml:"topics"`
+}
+
+// Export of the topics that the connectors have used since their creation or the last reset of their topics
+type Topics struct {
+	// reset the active topics of every connector on this interval, so that topics no longer used disappear.
+	// Topics are never reset when zero.
+	ResetInterval time.Duration `yaml:"reset_interval"`
+}
+
+// Export of the offsets of the connectors from the offsets endpoint of KIP-875
+type Offsets struct {
+	// the offsets of at most this many partitions are exported per connector, to limit the cardinality
+	MaxPartitions int `yaml:"max_partitions"`
+	// a connector with a running task is stalled when none of its offsets changed for this long
+	StallThreshold time.Duration `yaml:"stall_threshold"`
+	// the first threshold whose connectors pattern matches applies, otherwise the stall threshold
+	StallThresholds []StallThreshold `yaml:"stall_thresholds"`
+}
+
+type StallThreshold struct {
+	// patterns of connector names
+	Connectors []string      `yaml:"connectors"`
+	Threshold  time.Duration `yaml:"threshold"`
+}
+
+// The Jolokia agent of the workers, reached on the host of the REST API with another port and path
+type Jolokia struct {
+	Port int    `yaml:"port"`
+	Path string `yaml:"path"`
+	// http or https, the scheme of the REST API when empty
+	Scheme string `yaml:"scheme"`
+	// patterns or names of the kafka.connect MBeans to read
+	MBeans []string `yaml:"mbeans"`
+	// the agent has its own auth and TLS, independent of the REST API
+	Auth Auth `yaml:"auth"`
+	TLS  TLS  `yaml:"tls"`
+}
+
+// Files listing groups of targets with labels, in the format of the file_sd of Prometheus.
+// The files are re-read when their directories change and on every refresh interval.
+type FileDiscovery struct {
+	// glob patterns of JSON or YAML files
+	Files []string `yaml:"files"`
+	// the files are read on every change of their directories and on this interval, in case a change is missed
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+// Watches the pods or the endpoints of services in kubernetes, selected by namespace and labels
+type KubernetesDiscovery struct {
+	// pod or service
+	Role string `yaml:"role"`
+	// all namespaces when empty
+	Namespaces    []string `yaml:"namespaces"`
+	LabelSelector string   `yaml:"label_selector"`
+	// port of the kafka connect REST API on the pods
+	Port   int    `yaml:"port"`
+	Scheme string `yaml:"scheme"`
+	// path to a kubeconfig file, the in-cluster config is used when empty
+	Kubeconfig string `yaml:"kubeconfig"`
+}
+
+// Discovery of the clusters of Strimzi KafkaConnect resources, and of the drift between the desired state of
+// their KafkaConnector resources and the actual state of the connectors
+type Strimzi struct {
+	Enabled bool `yaml:"enabled"`
+	// all namespaces when empty
+	Namespaces []string `yaml:"namespaces"`
+	// selects the KafkaConnect resources
+	LabelSelector string `yaml:"label_selector"`
+	// path to a kubeconfig file, the in-cluster config is used when empty
+	Kubeconfig string `yaml:"kubeconfig"`
+	// the module with the timeout, labels, auth, TLS and filters of the discovered clusters
+	Module string `yaml:"module"`
+}
+
+// The settings of a cluster for targets given to the probe endpoint
+type Module struct {
+	Timeout time.Duration     `yaml:"timeout"`
+	Labels  map[string]string `yaml:"labels"`
+	Auth    Auth              `yaml:"auth"`
+	TLS     TLS               `yaml:"tls"`
+	Filters Filters           `yaml:"filters"`
+	Jolokia *Jolokia          `yaml:"jolokia"`
+	Offsets *Offsets          `yaml:"offsets"`
+	Topics  *Topics           `yaml:"topics"`
+	// regular expressions matched against the whole target url, any target is allowed when empty.
+	// Restrict the targets of modules with credentials, so that they are not sent to arbitrary hosts.
+	AllowedTargets []string `yaml:"allowed_targets"`
+}
+
+// Regular expressions matched against the whole connector name. Excluded connectors are never queried nor exported.
+type Filters struct {
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+}
+
+// Credentials sent to the kafka connect REST API. At most one of basic and bearer may be set.
+// Secrets can be read from files, which are re-read when they change, e.g. on rotation of a kubernetes secret.
+type Auth struct {
+	Basic  *BasicAuth  `yaml:"basic"`
+	Bearer *BearerAuth `yaml:"bearer"`
+}
+
+type BasicAuth struct {
+	Username     string `yaml:"username"`
+	Password     Secret `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+type BearerAuth struct {
+	Token     Secret `yaml:"token"`
+	TokenFile string `yaml:"token_file"`
+}
+
+// TLS settings for https urls. Certificate files are reloaded when they change on disk.
+type TLS struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// Opt-in automatic restart of failed connectors and tasks
+type Remediation struct {
+	Enabled bool `yaml:"enabled"`
+	// only log the restarts that would be done
+	DryRun bool `yaml:"dry_run"`
+	// connectors matching any of these patterns are never restarted
+	Exclude []string `yaml:"exclude"`
+	// the first policy whose connectors pattern matches applies, otherwise the default policy
+	Policies      []RestartPolicy `yaml:"policies"`
+	DefaultPolicy RestartPolicy   `yaml:"default_policy"`
+}
+
+type RestartPolicy struct {
+	// patterns of connector names, only used for policies
+	Connectors []string `yaml:"connectors"`
+	// maximum number of restarts within the window before giving up, 0 to never restart the matching connectors
+	MaxAttempts *int          `yaml:"max_attempts"`
+	Window      time.Duration `yaml:"window"`
+	// delay after the first restart, doubled after every further restart up to the max backoff
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+// Push of the metrics to an OpenTelemetry collector.
+// Unset fields fall back to the standard OTEL_EXPORTER_OTLP_* environment variables.
+type OTLP struct {
+	// grpc or http
+	Protocol string            `yaml:"protocol"`
+	Endpoint string            `yaml:"endpoint"`
+	Insecure bool              `yaml:"insecure"`
+	Headers  map[string]Secret `yaml:"headers"`
+	Interval time.Duration     `yaml:"interval"`
+	Timeout  time.Duration     `yaml:"timeout"`
+	// added to the resource of every cluster, next to the cluster name and its static labels
+	ResourceAttributes map[string]string `yaml:"resource_attributes"`
+}
+
+// Webhooks called when a connector or task changes its state
+type Notifications struct {
+	// an identical state change is sent at most once within the window, e.g. for a flapping task
+	DedupWindow time.Duration `yaml:"dedup_window"`
+	Webhooks    []Webhook     `yaml:"webhooks"`
+}
+
+type Webhook struct {
+	Name    string            `yaml:"name"`
+	URL     string            `yaml:"url"`
+	Headers map[string]Secret `yaml:"headers"`
+	// text/template rendering the request body from an event, the event as JSON when empty
+	Template string `yaml:"template"`
+	// the Content-Type header of the requests, application/json by default
+	ContentType string `yaml:"content_type"`
+	// only send events whose new state is one of these, all events when empty
+	States  []string      `yaml:"states"`
+	Timeout time.Duration `yaml:"timeout"`
+	// number of retries of a failed request, 0 to never retry, the delay between retries doubles after every retry
+	MaxRetries   *int          `yaml:"max_retries"`
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+}
+
+// A string that is never printed, so that credentials do not end up in logs
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "<secret>"
+}
+
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+const (
+	ExporterPrometheus = "prometheus"
+	ExporterOTLP       = "otlp"
+
+	OTLPProtocolGRPC = "grpc"
+	OTLPProtocolHTTP = "http"
+)
+
+const (
+	DefaultModuleName = "default"
+
+	// schemes of urls that are resolved to the hosts of a cluster
+	DNSSchemeSRV = "dns+srv"
+	DNSSchemeA   = "dns+a"
+
+	KubernetesRolePod     = "pod"
+	KubernetesRoleService = "service"
+
+	defaultClusterName    = "default"
+	defaultTimeout        = 10 * time.Second
+	defaultDedupWindow    = 5 * time.Minute
+	defaultMaxRetries     = 3
+	defaultContentType    = "application/json"
+	defaultRetryBackoff   = time.Second
+	defaultConnectPort    = 8083
+	defaultJolokiaPort    = 8778
+	defaultJolokiaPath    = "/jolokia"
+	defaultMaxPartitions  = 100
+	defaultStallThreshold = time.Hour
+)
+
+// The MBeans of the throughput, offset commits and errors of the workers, connectors and tasks
+var defaultJolokiaMBeans = []string{
+	"kafka.connect:type=connect-worker-metrics,*",
+	"kafka.connect:type=connect-worker-rebalance-metrics,*",
+	"kafka.connect:type=connector-task-metrics,*",
+	"kafka.connect:type=source-task-metrics,*",
+	"kafka.connect:type=sink-task-metrics,*",
+	"kafka.connect:type=task-error-metrics,*",
+}
+
+var defaultRestartPolicy = RestartPolicy{
+	MaxAttempts:    intPointer(3),
+	Window:         time.Hour,
+	InitialBackoff: time.Minute,
+	MaxBackoff:     30 * time.Minute,
+}
+
+var (
+	labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	// label names used by the exporter itself, static labels must not override them
+	reservedLabelNames = []string{"host", "connector", "status", "task", "worker_id", "state", "kind", "type", "class", "version", "commit", "kafka_cluster_id", "exception", "topic", "partition", "key"}
+	// label names of the hosts discovered in kubernetes, only reserved for the clusters that discover them
+	kubernetesLabelNames = []string{"namespace", "pod", "service"}
 )
 
 func getEnvWithDefault(key, fallback string) string {
@@ -12,9 +326,620 @@ func getEnvWithDefault(key, fallback string) string {
 	return fallback
 }
 
-var (
-	Port                = getEnvWithDefault("PORT", "9113")
-	MetricsEndpoint     = getEnvWithDefault("METRICS_ENDPOINT", "/metrics")
-	KafkaConnectHosts   = strings.Split(getEnvWithDefault("KAFKA_CONNECT_HOSTS", "http://localhost:4444"), ",")
-	HealthCheckEndpoint = getEnvWithDefault("HEALTH_CHECK_ENDPOINT", "/health")
-)
+func getDurationEnvWithDefault(key string, fallback time.Duration) (time.Duration, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, applicationError.New(http.StatusBadRequest, fmt.Sprintf("Invalid duration %q for %s", value, key), "")
+	}
+	return duration, nil
+}
+
+// Load the configuration from the given YAML file, or from the environment only when the path is empty.
+// Environment variables take precedence over the values of the file.
+// KAFKA_CONNECT_HOSTS replaces the clusters of the file with a single cluster named "default".
+func Load(path string) (*Config, error) {
+	config := &Config{
+		Port:                "9113",
+		MetricsEndpoint:     "/metrics",
+		HealthCheckEndpoint: "/health",
+		ProbeEndpoint:       "/probe",
+		PollInterval:        30 * time.Second,
+	}
+
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, applicationError.New(http.StatusBadRequest, fmt.Sprintf("Failed to read config file: %s", err.Error()), "")
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(config); err != nil {
+			return nil, applicationError.New(http.StatusBadRequest, fmt.Sprintf("Failed to parse config file %s: %s", path, err.Error()), "")
+		}
+	}
+
+	config.Port = getEnvWithDefault("PORT", config.Port)
+	config.MetricsEndpoint = getEnvWithDefault("METRICS_ENDPOINT", config.MetricsEndpoint)
+	config.HealthCheckEndpoint = getEnvWithDefault("HEALTH_CHECK_ENDPOINT", config.HealthCheckEndpoint)
+	config.ProbeEndpoint = getEnvWithDefault("PROBE_ENDPOINT", config.ProbeEndpoint)
+	config.DNS.Resolver = getEnvWithDefault("DNS_RESOLVER", config.DNS.Resolver)
+
+	pollInterval, err := getDurationEnvWithDefault("POLL_INTERVAL", config.PollInterval)
+	if err != nil {
+		return nil, err
+	}
+	config.PollInterval = pollInterval
+
+	refreshInterval, err := getDurationEnvWithDefault("DNS_REFRESH_INTERVAL", config.DNS.RefreshInterval)
+	if err != nil {
+		return nil, err
+	}
+	config.DNS.RefreshInterval = refreshInterval
+	if config.DNS.RefreshInterval == 0 {
+		config.DNS.RefreshInterval = config.PollInterval
+	}
+
+	if exporters, ok := os.LookupEnv("EXPORTERS"); ok {
+		config.Exporters = strings.Split(exporters, ",")
+	}
+	if len(config.Exporters) == 0 {
+		config.Exporters = []string{ExporterPrometheus}
+	}
+	if config.OTLP.Protocol == "" {
+		config.OTLP.Protocol = OTLPProtocolGRPC
+	}
+	if config.OTLP.Interval == 0 {
+		config.OTLP.Interval = config.PollInterval
+	}
+	if config.OTLP.Timeout == 0 {
+		config.OTLP.Timeout = defaultTimeout
+	}
+
+	// with modules or strimzi and without clusters, only probed or discovered clusters are collected
+	if hosts, ok := os.LookupEnv("KAFKA_CONNECT_HOSTS"); ok || (len(config.Clusters) == 0 && len(config.Modules) == 0 && !config.Strimzi.Enabled) {
+		if !ok {
+			hosts = "http://localhost:4444"
+		}
+		config.Clusters = []Cluster{{Name: defaultClusterName, URLs: strings.Split(hosts, ",")}}
+	}
+
+	for i := range config.Clusters {
+		if config.Clusters[i].Timeout == 0 {
+			config.Clusters[i].Timeout = defaultTimeout
+		}
+		if kubernetes := config.Clusters[i].Kubernetes; kubernetes != nil {
+			if kubernetes.Role == "" {
+				kubernetes.Role = KubernetesRolePod
+			}
+			if kubernetes.Port == 0 {
+				kubernetes.Port = defaultConnectPort
+			}
+			if kubernetes.Scheme == "" {
+				kubernetes.Scheme = "http"
+			}
+		}
+		if fileSD := config.Clusters[i].FileSD; fileSD != nil && fileSD.RefreshInterval == 0 {
+			fileSD.RefreshInterval = config.PollInterval
+		}
+		if jolokia := config.Clusters[i].Jolokia; jolokia != nil {
+			jolokia.setDefaults()
+		}
+		if offsets := config.Clusters[i].Offsets; offsets != nil {
+			offsets.setDefaults()
+		}
+	}
+
+	if config.Modules == nil {
+		config.Modules = map[string]Module{}
+	}
+	if _, ok := config.Modules[DefaultModuleName]; !ok {
+		config.Modules[DefaultModuleName] = Module{}
+		config.implicitDefaultModule = true
+	}
+	if config.Strimzi.Module == "" {
+		config.Strimzi.Module = DefaultModuleName
+	}
+	for name, module := range config.Modules {
+		if module.Jolokia != nil {
+			module.Jolokia.setDefaults()
+		}
+		if module.Offsets != nil {
+			module.Offsets.setDefaults()
+		}
+		if module.Timeout == 0 {
+			module.Timeout = defaultTimeout
+			config.Modules[name] = module
+		}
+	}
+
+	config.Remediation.DefaultPolicy.setDefaults(defaultRestartPolicy)
+	for i := range config.Remediation.Policies {
+		config.Remediation.Policies[i].setDefaults(config.Remediation.DefaultPolicy)
+	}
+
+	if config.Notifications.DedupWindow == 0 {
+		config.Notifications.DedupWindow = defaultDedupWindow
+	}
+	for i := range config.Notifications.Webhooks {
+		webhook := &config.Notifications.Webhooks[i]
+		if webhook.Timeout == 0 {
+			webhook.Timeout = defaultTimeout
+		}
+		if webhook.MaxRetries == nil {
+			webhook.MaxRetries = intPointer(defaultMaxRetries)
+		}
+		if webhook.RetryBackoff == 0 {
+			webhook.RetryBackoff = defaultRetryBackoff
+		}
+		if webhook.ContentType == "" {
+			webhook.ContentType = defaultContentType
+		}
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (c *Config) validate() error {
+	if c.PollInterval <= 0 {
+		return invalid("poll_interval must be positive, got %s", c.PollInterval)
+	}
+
+	names := map[string]bool{}
+	for i, cluster := range c.Clusters {
+		if cluster.Name == "" {
+			return invalid("clusters[%d]: name is required", i)
+		}
+		if names[cluster.Name] {
+			return invalid("clusters[%d]: duplicate cluster name %q", i, cluster.Name)
+		}
+		names[cluster.Name] = true
+
+		if err := cluster.validate(); err != nil {
+			return invalid("cluster %q: %s", cluster.Name, err.Error())
+		}
+	}
+
+	if err := c.Remediation.validate(); err != nil {
+		return invalid("remediation: %s", err.Error())
+	}
+
+	for name, module := range c.Modules {
+		if err := module.validate(); err != nil {
+			return invalid("module %q: %s", name, err.Error())
+		}
+	}
+
+	if c.Strimzi.Enabled {
+		if _, ok := c.Modules[c.Strimzi.Module]; !ok {
+			return invalid("strimzi: unknown module %q", c.Strimzi.Module)
+		}
+		if _, err := labels.Parse(c.Strimzi.LabelSelector); err != nil {
+			return invalid("strimzi: invalid label_selector: %s", err.Error())
+		}
+		if err := validateReservedLabels(c.Modules[c.Strimzi.Module].Labels, []string{"namespace"}); err != nil {
+			return invalid("strimzi: %s", err.Error())
+		}
+	}
+
+	for _, exporter := range c.Exporters {
+		if exporter != ExporterPrometheus && exporter != ExporterOTLP {
+			return invalid("invalid exporter %q, expected %s or %s", exporter, ExporterPrometheus, ExporterOTLP)
+		}
+	}
+	if c.OTLP.Protocol != OTLPProtocolGRPC && c.OTLP.Protocol != OTLPProtocolHTTP {
+		return invalid("otlp: invalid protocol %q, expected %s or %s", c.OTLP.Protocol, OTLPProtocolGRPC, OTLPProtocolHTTP)
+	}
+	if c.DNS.RefreshInterval < 0 {
+		return invalid("dns: refresh_interval must not be negative, got %s", c.DNS.RefreshInterval)
+	}
+	if c.DNS.Resolver != "" {
+		if _, _, err := net.SplitHostPort(c.DNS.Resolver); err != nil {
+			return invalid("dns: invalid resolver %q, expected host:port", c.DNS.Resolver)
+		}
+	}
+
+	if c.OTLP.Interval < 0 || c.OTLP.Timeout < 0 {
+		return invalid("otlp: interval and timeout must not be negative")
+	}
+
+	if err := c.Notifications.validate(); err != nil {
+		return invalid("notifications: %s", err.Error())
+	}
+	return nil
+}
+
+// Whether the metrics are served on the metrics endpoint
+func (c *Config) ExportsPrometheus() bool {
+	return slices.Contains(c.Exporters, ExporterPrometheus)
+}
+
+// The modules of the probe endpoint, without the default module unless it is configured.
+// The probe endpoint is only served with configured modules, so that it is not an open proxy to any target by default.
+func (c *Config) ProbeModules() map[string]Module {
+	modules := maps.Clone(c.Modules)
+	if c.implicitDefaultModule {
+		delete(modules, DefaultModuleName)
+	}
+	return modules
+}
+
+// Whether the metrics are pushed to an OpenTelemetry collector
+func (c *Config) ExportsOTLP() bool {
+	return slices.Contains(c.Exporters, ExporterOTLP)
+}
+
+func (c *Cluster) validate() error {
+	if len(c.URLs) == 0 && !c.discovered() {
+		return fmt.Errorf("at least one url is required")
+	}
+	for _, rawURL := range c.URLs {
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid url %q, expected http(s)://host:port", rawURL)
+		}
+		switch u.Scheme {
+		case "http", "https":
+		case DNSSchemeSRV, DNSSchemeA:
+			if scheme := u.Query().Get("scheme"); scheme != "" && scheme != "http" && scheme != "https" {
+				return fmt.Errorf("invalid scheme %q of url %q, expected http or https", scheme, rawURL)
+			}
+		default:
+			return fmt.Errorf("invalid url %q, expected http(s)://host:port", rawURL)
+		}
+	}
+
+	if c.Kubernetes != nil {
+		if err := c.Kubernetes.validate(); err != nil {
+			return fmt.Errorf("kubernetes: %s", err.Error())
+		}
+		if err := validateReservedLabels(c.Labels, kubernetesLabelNames); err != nil {
+			return fmt.Errorf("kubernetes: %s", err.Error())
+		}
+	}
+	if c.FileSD != nil {
+		if err := c.FileSD.validate(); err != nil {
+			return fmt.Errorf("file_sd: %s", err.Error())
+		}
+	}
+
+	module := c.Module()
+	return module.validate()
+}
+
+// Whether the urls of the cluster are discovered at runtime
+func (c *Cluster) discovered() bool {
+	return c.Kubernetes != nil || c.FileSD != nil
+}
+
+// The http(s) urls of the cluster, without the urls that are resolved with DNS
+func (c *Cluster) StaticURLs() []string {
+	var urls []string
+	for _, url := range c.URLs {
+		if !IsDNSURL(url) {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// The dns+srv:// and dns+a:// urls of the cluster
+func (c *Cluster) DNSURLs() []string {
+	var urls []string
+	for _, url := range c.URLs {
+		if IsDNSURL(url) {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+func IsDNSURL(url string) bool {
+	return strings.HasPrefix(url, DNSSchemeSRV+"://") || strings.HasPrefix(url, DNSSchemeA+"://")
+}
+
+func (k *KubernetesDiscovery) validate() error {
+	if k.Role != KubernetesRolePod && k.Role != KubernetesRoleService {
+		return fmt.Errorf("invalid role %q, expected %s or %s", k.Role, KubernetesRolePod, KubernetesRoleService)
+	}
+	if _, err := labels.Parse(k.LabelSelector); err != nil {
+		return fmt.Errorf("invalid label_selector: %s", err.Error())
+	}
+	if k.Port < 1 || k.Port > 65535 {
+		return fmt.Errorf("invalid port %d", k.Port)
+	}
+	if k.Scheme != "http" && k.Scheme != "https" {
+		return fmt.Errorf("invalid scheme %q, expected http or https", k.Scheme)
+	}
+	return nil
+}
+
+func (f *FileDiscovery) validate() error {
+	if len(f.Files) == 0 {
+		return fmt.Errorf("at least one file is required")
+	}
+	for _, pattern := range f.Files {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	if f.RefreshInterval < 0 {
+		return fmt.Errorf("refresh_interval must not be negative, got %s", f.RefreshInterval)
+	}
+	return nil
+}
+
+// The settings of the cluster that do not depend on its urls
+func (c *Cluster) Module() Module {
+	return Module{Timeout: c.Timeout, Labels: c.Labels, Auth: c.Auth, TLS: c.TLS, Filters: c.Filters, Jolokia: c.Jolokia, Offsets: c.Offsets, Topics: c.Topics}
+}
+
+// A cluster of the given name that uses the settings of the module
+func (m *Module) Cluster(name string, urls ...string) Cluster {
+	return Cluster{Name: name, URLs: urls, Timeout: m.Timeout, Labels: m.Labels, Auth: m.Auth, TLS: m.TLS, Filters: m.Filters, Jolokia: m.Jolokia, Offsets: m.Offsets, Topics: m.Topics}
+}
+
+func (m *Module) validate() error {
+	if m.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative, got %s", m.Timeout)
+	}
+
+	if err := m.Auth.validate(); err != nil {
+		return err
+	}
+
+	if err := m.TLS.validate(); err != nil {
+		return err
+	}
+
+	if err := validatePatterns("filters", append(append([]string{}, m.Filters.Include...), m.Filters.Exclude...)); err != nil {
+		return err
+	}
+
+	if err := validatePatterns("allowed_targets", m.AllowedTargets); err != nil {
+		return err
+	}
+
+	if m.Jolokia != nil {
+		if err := m.Jolokia.validate(); err != nil {
+			return fmt.Errorf("jolokia: %s", err.Error())
+		}
+	}
+
+	if m.Offsets != nil {
+		if err := m.Offsets.validate(); err != nil {
+			return fmt.Errorf("offsets: %s", err.Error())
+		}
+	}
+
+	if m.Topics != nil && m.Topics.ResetInterval < 0 {
+		return fmt.Errorf("topics: reset_interval must not be negative, got %s", m.Topics.ResetInterval)
+	}
+
+	return ValidateLabels(m.Labels)
+}
+
+// Check that the names of static labels are valid and not used by the labels of the exporter
+func ValidateLabels(labels map[string]string) error {
+	for name := range labels {
+		if !labelNamePattern.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	return validateReservedLabels(labels, reservedLabelNames)
+}
+
+func validateReservedLabels(labels map[string]string, reserved []string) error {
+	for _, name := range reserved {
+		if _, ok := labels[name]; ok {
+			return fmt.Errorf("label name %q is reserved by the exporter", name)
+		}
+	}
+	return nil
+}
+
+func (a *Auth) validate() error {
+	if a.Basic != nil && a.Bearer != nil {
+		return fmt.Errorf("auth: only one of basic and bearer can be set")
+	}
+	if a.Basic != nil {
+		if a.Basic.Username == "" {
+			return fmt.Errorf("auth.basic: username is required")
+		}
+		if (a.Basic.Password == "") == (a.Basic.PasswordFile == "") {
+			return fmt.Errorf("auth.basic: exactly one of password and password_file is required")
+		}
+	}
+	if a.Bearer != nil && (a.Bearer.Token == "") == (a.Bearer.TokenFile == "") {
+		return fmt.Errorf("auth.bearer: exactly one of token and token_file is required")
+	}
+	return nil
+}
+
+func (t *TLS) validate() error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("tls: cert_file and key_file must be set together")
+	}
+	if t.InsecureSkipVerify && t.CAFile != "" {
+		return fmt.Errorf("tls: ca_file has no effect with insecure_skip_verify")
+	}
+	for _, path := range []string{t.CAFile, t.CertFile, t.KeyFile} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("tls: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+func (r *Remediation) validate() error {
+	if err := validatePatterns("exclude", r.Exclude); err != nil {
+		return err
+	}
+	if err := r.DefaultPolicy.validate(); err != nil {
+		return fmt.Errorf("default_policy: %s", err.Error())
+	}
+	for i, policy := range r.Policies {
+		if len(policy.Connectors) == 0 {
+			return fmt.Errorf("policies[%d]: connectors is required", i)
+		}
+		if err := validatePatterns("connectors", policy.Connectors); err != nil {
+			return fmt.Errorf("policies[%d]: %s", i, err.Error())
+		}
+		if err := policy.validate(); err != nil {
+			return fmt.Errorf("policies[%d]: %s", i, err.Error())
+		}
+	}
+	return nil
+}
+
+func (n *Notifications) validate() error {
+	if n.DedupWindow < 0 {
+		return fmt.Errorf("dedup_window must not be negative, got %s", n.DedupWindow)
+	}
+	names := map[string]bool{}
+	for i, webhook := range n.Webhooks {
+		if webhook.Name == "" {
+			return fmt.Errorf("webhooks[%d]: name is required", i)
+		}
+		if names[webhook.Name] {
+			return fmt.Errorf("webhooks[%d]: duplicate webhook name %q", i, webhook.Name)
+		}
+		names[webhook.Name] = true
+
+		if err := webhook.validate(); err != nil {
+			return fmt.Errorf("webhook %q: %s", webhook.Name, err.Error())
+		}
+	}
+	return nil
+}
+
+func (w *Webhook) validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		// the url is not printed, because the url of a webhook, e.g. of Slack or Teams, is a secret
+		return fmt.Errorf("invalid url, expected http(s)://host")
+	}
+	for _, state := range w.States {
+		if !slices.Contains(states.Connectors, state) {
+			return fmt.Errorf("invalid state %q, expected one of %s", state, strings.Join(states.Connectors, ", "))
+		}
+	}
+	if w.Timeout < 0 || *w.MaxRetries < 0 || w.RetryBackoff < 0 {
+		return fmt.Errorf("timeout, max_retries and retry_backoff must not be negative")
+	}
+	return nil
+}
+
+func (o *Offsets) setDefaults() {
+	if o.MaxPartitions == 0 {
+		o.MaxPartitions = defaultMaxPartitions
+	}
+	if o.StallThreshold == 0 {
+		o.StallThreshold = defaultStallThreshold
+	}
+}
+
+func (o *Offsets) validate() error {
+	if o.MaxPartitions < 0 {
+		return fmt.Errorf("max_partitions must not be negative, got %d", o.MaxPartitions)
+	}
+	if o.StallThreshold < 0 {
+		return fmt.Errorf("stall_threshold must not be negative, got %s", o.StallThreshold)
+	}
+	for i, threshold := range o.StallThresholds {
+		if len(threshold.Connectors) == 0 {
+			return fmt.Errorf("stall_thresholds[%d]: at least one connectors pattern is required", i)
+		}
+		if threshold.Threshold <= 0 {
+			return fmt.Errorf("stall_thresholds[%d]: threshold must be positive, got %s", i, threshold.Threshold)
+		}
+		if err := validatePatterns(fmt.Sprintf("stall_thresholds[%d]", i), threshold.Connectors); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *Jolokia) setDefaults() {
+	if j.Port == 0 {
+		j.Port = defaultJolokiaPort
+	}
+	if j.Path == "" {
+		j.Path = defaultJolokiaPath
+	}
+	if len(j.MBeans) == 0 {
+		j.MBeans = defaultJolokiaMBeans
+	}
+}
+
+func (j *Jolokia) validate() error {
+	if j.Port < 1 || j.Port > 65535 {
+		return fmt.Errorf("invalid port %d", j.Port)
+	}
+	if !strings.HasPrefix(j.Path, "/") {
+		return fmt.Errorf("invalid path %q, expected an absolute path", j.Path)
+	}
+	if j.Scheme != "" && j.Scheme != "http" && j.Scheme != "https" {
+		return fmt.Errorf("invalid scheme %q, expected http or https", j.Scheme)
+	}
+	for _, mbean := range j.MBeans {
+		if !strings.HasPrefix(mbean, "kafka.connect:") {
+			return fmt.Errorf("invalid mbean %q, expected a pattern in the kafka.connect domain", mbean)
+		}
+	}
+	if err := j.Auth.validate(); err != nil {
+		return err
+	}
+	return j.TLS.validate()
+}
+
+func (p *RestartPolicy) setDefaults(defaults RestartPolicy) {
+	if p.MaxAttempts == nil {
+		p.MaxAttempts = intPointer(*defaults.MaxAttempts)
+	}
+	if p.Window == 0 {
+		p.Window = defaults.Window
+	}
+	if p.InitialBackoff == 0 {
+		p.InitialBackoff = defaults.InitialBackoff
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = defaults.MaxBackoff
+	}
+}
+
+func (p *RestartPolicy) validate() error {
+	if *p.MaxAttempts < 0 || p.Window < 0 || p.InitialBackoff < 0 || p.MaxBackoff < 0 {
+		return fmt.Errorf("max_attempts, window, initial_backoff and max_backoff must not be negative")
+	}
+	if p.MaxBackoff < p.InitialBackoff {
+		return fmt.Errorf("max_backoff must not be less than initial_backoff")
+	}
+	return nil
+}
+
+func intPointer(value int) *int {
+	return &value
+}
+
+func validatePatterns(key string, patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("%s: invalid regular expression %q: %s", key, pattern, err.Error())
+		}
+	}
+	return nil
+}
+
+func invalid(format string, args ...any) error {
+	return applicationError.New(http.StatusBadRequest, "Invalid config: "+fmt.Sprintf(format, args...), "")
+}
@@ -1,8 +1,13 @@
 package config
 
 import (
+	"fmt"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -23,3 +28,529 @@ func TestGetEnvWithDefault(t *testing.T) {
 		assert.Equal(t, "default", value)
 	})
 }
+
+func TestGetDurationEnvWithDefault(t *testing.T) {
+	t.Run("Should return the parsed duration of an environment variable", func(t *testing.T) {
+		t.Setenv("TEST_ENV_VAR", "15s")
+
+		value, err := getDurationEnvWithDefault("TEST_ENV_VAR", time.Minute)
+		assert.Nil(t, err)
+		assert.Equal(t, 15*time.Second, value)
+	})
+
+	t.Run("Should return an error when the environment variable is not a valid duration", func(t *testing.T) {
+		t.Setenv("TEST_ENV_VAR", "soon")
+
+		_, err := getDurationEnvWithDefault("TEST_ENV_VAR", time.Minute)
+		assert.EqualError(t, err, `Invalid duration "soon" for TEST_ENV_VAR`)
+	})
+
+	t.Run("Should return the default value when the environment variable is not set", func(t *testing.T) {
+		value, err := getDurationEnvWithDefault("TEST_ENV_VAR", time.Minute)
+		assert.Nil(t, err)
+		assert.Equal(t, time.Minute, value)
+	})
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("Should load the defaults without a config file", func(t *testing.T) {
+		config, err := Load("")
+		assert.Nil(t, err)
+		assert.Equal(t, "9113", config.Port)
+		assert.Equal(t, "/metrics", config.MetricsEndpoint)
+		assert.Equal(t, "/health", config.HealthCheckEndpoint)
+		assert.Equal(t, 30*time.Second, config.PollInterval)
+		assert.Equal(t, []Cluster{{Name: "default", URLs: []string{"http://localhost:4444"}, Timeout: 10 * time.Second}}, config.Clusters)
+		assert.True(t, config.ExportsPrometheus())
+		assert.False(t, config.ExportsOTLP())
+		assert.Equal(t, "/probe", config.ProbeEndpoint)
+		assert.Equal(t, map[string]Module{"default": {Timeout: 10 * time.Second}}, config.Modules)
+		assert.Empty(t, config.ProbeModules())
+	})
+
+	t.Run("Should only serve probes with modules and without clusters", func(t *testing.T) {
+		path := writeConfigFile(t, `
+modules:
+  prod:
+    timeout: 5s
+    auth:
+      bearer: {token_file: /var/run/secrets/connect/token}
+    allowed_targets: ["https://connect-.*\\.prod\\.internal:8083"]
+`)
+
+		config, err := Load(path)
+		assert.Nil(t, err)
+		assert.Empty(t, config.Clusters)
+		assert.Equal(t, map[string]Module{
+			"default": {Timeout: 10 * time.Second},
+			"prod": {
+				Timeout:        5 * time.Second,
+				Auth:           Auth{Bearer: &BearerAuth{TokenFile: "/var/run/secrets/connect/token"}},
+				AllowedTargets: []string{`https://connect-.*\.prod\.internal:8083`},
+			},
+		}, config.Modules)
+		assert.Equal(t, []string{"prod"}, slices.Collect(maps.Keys(config.ProbeModules())))
+	})
+
+	t.Run("Should select the exporters from the environment", func(t *testing.T) {
+		t.Setenv("EXPORTERS", "otlp")
+		path := writeConfigFile(t, `
+poll_interval: 15s
+otlp:
+  protocol: http
+  endpoint: https://otel-collector:4318
+`)
+
+		config, err := Load(path)
+		assert.Nil(t, err)
+		assert.False(t, config.ExportsPrometheus())
+		assert.True(t, config.ExportsOTLP())
+		assert.Equal(t, OTLP{Protocol: "http", Endpoint: "https://otel-collector:4318", Interval: 15 * time.Second, Timeout: 10 * time.Second}, config.OTLP)
+	})
+
+	t.Run("Should load clusters from the config file", func(t *testing.T) {
+		path := writeConfigFile(t, `
+port: "9200"
+poll_interval: 1m
+clusters:
+  - name: prod
+    urls:
+      - http://connect-1:8083
+      - http://connect-2:8083
+    timeout: 5s
+    labels:
+      env: prod
+  - name: staging
+    urls: [http://connect-staging:8083]
+`)
+
+		config, err := Load(path)
+		assert.Nil(t, err)
+		assert.Equal(t, "9200", config.Port)
+		assert.Equal(t, time.Minute, config.PollInterval)
+		assert.Equal(t, []Cluster{
+			{Name: "prod", URLs: []string{"http://connect-1:8083", "http://connect-2:8083"}, Timeout: 5 * time.Second, Labels: map[string]string{"env": "prod"}},
+			{Name: "staging", URLs: []string{"http://connect-staging:8083"}, Timeout: 10 * time.Second},
+		}, config.Clusters)
+	})
+
+	t.Run("Should load the credentials of a cluster", func(t *testing.T) {
+		path := writeConfigFile(t, `
+clusters:
+  - name: prod
+    urls: [http://connect-1:8083]
+    auth:
+      basic:
+        username: exporter
+        password_file: /var/run/secrets/connect/password
+`)
+
+		config, err := Load(path)
+		assert.Nil(t, err)
+		assert.Equal(t, Auth{Basic: &BasicAuth{Username: "exporter", PasswordFile: "/var/run/secrets/connect/password"}}, config.Clusters[0].Auth)
+	})
+
+	t.Run("Should inherit the unset fields of restart policies from the default policy", func(t *testing.T) {
+		path := writeConfigFile(t, `
+remediation:
+  enabled: true
+  policies:
+    - connectors: ["orders-.*"]
+      max_attempts: 10
+    - connectors: ["sandbox-.*"]
+      max_attempts: 0
+  default_policy:
+    window: 2h
+`)
+
+		config, err := Load(path)
+		assert.Nil(t, err)
+		assert.Equal(t, RestartPolicy{MaxAttempts: intPointer(3), Window: 2 * time.Hour, InitialBackoff: time.Minute, MaxBackoff: 30 * time.Minute}, config.Remediation.DefaultPolicy)
+		assert.Equal(t, RestartPolicy{Connectors: []string{"orders-.*"}, MaxAttempts: intPointer(10), Window: 2 * time.Hour, InitialBackoff: time.Minute, MaxBackoff: 30 * time.Minute}, config.Remediation.Policies[0])
+		assert.Equal(t, 0, *config.Remediation.Policies[1].MaxAttempts)
+	})
+
+	t.Run("Should apply the defaults of webhooks", func(t *testing.T) {
+		path := writeConfigFile(t, `
+notifications:
+  webhooks:
+    - name: alerts
+      url: https://hooks.example.com/connect
+      headers:
+        Authorization: Bearer token
+      states: [FAILED]
+`)
+
+		config, err := Load(path)
+		assert.Nil(t, err)
+		assert.Equal(t, Notifications{DedupWindow: 5 * time.Minute, Webhooks: []Webhook{{
+			Name:         "alerts",
+			URL:          "https://hooks.example.com/connect",
+			Headers:      map[string]Secret{"Authorization": "Bearer token"},
+			States:       []string{"FAILED"},
+			Timeout:      10 * time.Second,
+			MaxRetries:   intPointer(3),
+			RetryBackoff: time.Second,
+			ContentType:  "application/json",
+		}}}, config.Notifications)
+	})
+
+	t.Run("Should apply the defaults of kubernetes discovery", func(t *testing.T) {
+		path := writeConfigFile(t, `
+clusters:
+  - name: prod
+    kubernetes:
+      namespaces: [kafka]
+      label_selector: app=connect
+`)
+
+		config, err := Load(path)
+		assert.Nil(t, err)
+		assert.Empty(t, config.Clusters[0].URLs)
+		assert.Equal(t, &KubernetesDiscovery{Role: "pod", Namespaces: []string{"kafka"}, LabelSelector: "app=connect", Port: 8083, Scheme: "http"}, config.Clusters[0].Kubernetes)
+	})
+
+	t.Run("Should refresh the files of file discovery on the poll interval by default", func(t *testing.T) {
+		path := writeConfigFile(t, `
+poll_interval: 15s
+clusters:
+  - name: prod
+    file_sd:
+      files: [/etc/kafka-connect-exporter/targets/*.json]
+`)
+
+		config, err := Load(path)
+		assert.Nil(t, err)
+		assert.Empty(t, config.Clusters[0].URLs)
+		assert.Equal(t, &FileDiscovery{Files: []string{"/etc/kafka-connect-exporter/targets/*.json"}, RefreshInterval: 15 * time.Second}, config.Clusters[0].FileSD)
+	})
+
+	t.Run("Should apply the defaults of jolokia and offsets", func(t *testing.T) {
+		path := writeConfigFile(t, `
+clusters:
+  - name: prod
+    urls: [http://connect-1:8083]
+    jolokia: {}
+    offsets: {}
+modules:
+  jmx:
+    jolokia:
+      port: 8779
+      mbeans: ["kafka.connect:type=sink-task-metrics,*"]
+`)
+
+		config, err := Load(path)
+		assert.Nil(t, err)
+		assert.Equal(t, &Jolokia{Port: 8778, Path: "/jolokia", MBeans: defaultJolokiaMBeans}, config.Clusters[0].Jolokia)
+		assert.Equal(t, &Offsets{MaxPartitions: 100, StallThreshold: time.Hour}, config.Clusters[0].Offsets)
+		assert.Equal(t, &Jolokia{Port: 8779, Path: "/jolokia", MBeans: []string{"kafka.connect:type=sink-task-metrics,*"}}, config.Modules["jmx"].Jolokia)
+	})
+
+	t.Run("Should only collect discovered clusters with strimzi and without clusters", func(t *testing.T) {
+		path := writeConfigFile(t, `
+strimzi:
+  enabled: true
+  namespaces: [kafka]
+`)
+
+		config, err := Load(path)
+		assert.Nil(t, err)
+		assert.Empty(t, config.Clusters)
+		assert.Equal(t, Strimzi{Enabled: true, Namespaces: []string{"kafka"}, Module: "default"}, config.Strimzi)
+	})
+
+	t.Run("Should separate the urls resolved with DNS from the static urls", func(t *testing.T) {
+		t.Setenv("KAFKA_CONNECT_HOSTS", "http://connect-1:8083,dns+srv://_connect._tcp.example.com,dns+a://connect.example.com:8083?scheme=https")
+		t.Setenv("DNS_REFRESH_INTERVAL", "1m")
+
+		config, err := Load("")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"http://connect-1:8083"}, config.Clusters[0].StaticURLs())
+		assert.Equal(t, []string{"dns+srv://_connect._tcp.example.com", "dns+a://connect.example.com:8083?scheme=https"}, config.Clusters[0].DNSURLs())
+		assert.Equal(t, DNS{RefreshInterval: time.Minute}, config.DNS)
+	})
+
+	t.Run("Should override the config file with environment variables", func(t *testing.T) {
+		t.Setenv("PORT", "9300")
+		t.Setenv("KAFKA_CONNECT_HOSTS", "http://connect-a:8083,http://connect-b:8083")
+		path := writeConfigFile(t, `
+port: "9200"
+clusters:
+  - name: prod
+    urls: [http://connect-1:8083]
+`)
+
+		config, err := Load(path)
+		assert.Nil(t, err)
+		assert.Equal(t, "9300", config.Port)
+		assert.Equal(t, []Cluster{{Name: "default", URLs: []string{"http://connect-a:8083", "http://connect-b:8083"}, Timeout: 10 * time.Second}}, config.Clusters)
+	})
+
+	t.Run("Should return an error for unknown fields", func(t *testing.T) {
+		path := writeConfigFile(t, `
+clusters:
+  - name: prod
+    url: http://connect-1:8083
+`)
+
+		_, err := Load(path)
+		assert.ErrorContains(t, err, "field url not found")
+	})
+
+	t.Run("Should return an error for a missing config file", func(t *testing.T) {
+		_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+		assert.ErrorContains(t, err, "Failed to read config file")
+	})
+
+	t.Run("Should return a validation error on bad input", func(t *testing.T) {
+		tests := map[string]string{
+			"clusters[0]: name is required": `
+clusters:
+  - urls: [http://connect-1:8083]
+`,
+			`clusters[1]: duplicate cluster name "prod"`: `
+clusters:
+  - name: prod
+    urls: [http://connect-1:8083]
+  - name: prod
+    urls: [http://connect-2:8083]
+`,
+			`cluster "prod": at least one url is required`: `
+clusters:
+  - name: prod
+`,
+			`cluster "prod": invalid url "connect-1:8083", expected http(s)://host:port`: `
+clusters:
+  - name: prod
+    urls: [connect-1:8083]
+`,
+			`cluster "prod": label name "host" is reserved by the exporter`: `
+clusters:
+  - name: prod
+    urls: [http://connect-1:8083]
+    labels:
+      host: connect-1
+`,
+			`cluster "prod": invalid label name "env-name"`: `
+clusters:
+  - name: prod
+    urls: [http://connect-1:8083]
+    labels:
+      env-name: prod
+`,
+			`cluster "prod": auth: only one of basic and bearer can be set`: `
+clusters:
+  - name: prod
+    urls: [http://connect-1:8083]
+    auth:
+      basic: {username: user, password: password}
+      bearer: {token: token}
+`,
+			`cluster "prod": auth.basic: exactly one of password and password_file is required`: `
+clusters:
+  - name: prod
+    urls: [http://connect-1:8083]
+    auth:
+      basic: {username: user}
+`,
+			`cluster "prod": auth.bearer: exactly one of token and token_file is required`: `
+clusters:
+  - name: prod
+    urls: [http://connect-1:8083]
+    auth:
+      bearer: {token: token, token_file: /var/run/secrets/token}
+`,
+			`cluster "prod": tls: cert_file and key_file must be set together`: `
+clusters:
+  - name: prod
+    urls: [https://connect-1:8083]
+    tls:
+      cert_file: /etc/connect/tls.crt
+`,
+			`cluster "prod": tls: stat /missing/ca.pem: no such file or directory`: `
+clusters:
+  - name: prod
+    urls: [https://connect-1:8083]
+    tls:
+      ca_file: /missing/ca.pem
+`,
+			`cluster "prod": filters: invalid regular expression "test-(": error parsing regexp: missing closing ): ` + "`test-(`": `
+clusters:
+  - name: prod
+    urls: [http://connect-1:8083]
+    filters:
+      exclude: ["test-("]
+`,
+			"remediation: policies[0]: connectors is required": `
+remediation:
+  policies:
+    - max_attempts: 5
+`,
+			"remediation: default_policy: max_backoff must not be less than initial_backoff": `
+remediation:
+  default_policy:
+    initial_backoff: 1h
+`,
+			`notifications: webhooks[1]: duplicate webhook name "alerts"`: `
+notifications:
+  webhooks:
+    - {name: alerts, url: "http://hooks-1"}
+    - {name: alerts, url: "http://hooks-2"}
+`,
+			`notifications: webhook "alerts": invalid url, expected http(s)://host`: `
+notifications:
+  webhooks:
+    - name: alerts
+      url: hooks.slack.com/services/T000/B000/secret
+`,
+			`notifications: webhook "alerts": invalid state "BROKEN", expected one of RUNNING, PAUSED, FAILED, UNASSIGNED, RESTARTING, STOPPED`: `
+notifications:
+  webhooks:
+    - name: alerts
+      url: http://hooks
+      states: [BROKEN]
+`,
+			`invalid exporter "statsd", expected prometheus or otlp`: `
+exporters: [prometheus, statsd]
+`,
+			`otlp: invalid protocol "udp", expected grpc or http`: `
+otlp:
+  protocol: udp
+`,
+			`module "prod": label name "connector" is reserved by the exporter`: `
+modules:
+  prod:
+    labels:
+      connector: prod
+`,
+			`cluster "prod": kubernetes: invalid role "node", expected pod or service`: `
+clusters:
+  - name: prod
+    kubernetes:
+      role: node
+`,
+			`cluster "prod": kubernetes: invalid label_selector: unable to parse requirement: found '(', expected: ',', ')' or identifier`: `
+clusters:
+  - name: prod
+    kubernetes:
+      label_selector: "app in ((connect)"
+`,
+			`cluster "prod": kubernetes: label name "namespace" is reserved by the exporter`: `
+clusters:
+  - name: prod
+    labels:
+      namespace: kafka
+    kubernetes:
+      role: pod
+`,
+			`strimzi: label name "namespace" is reserved by the exporter`: `
+modules:
+  default:
+    labels:
+      namespace: kafka
+strimzi:
+  enabled: true
+`,
+			`cluster "prod": file_sd: at least one file is required`: `
+clusters:
+  - name: prod
+    file_sd:
+      refresh_interval: 1m
+`,
+			`cluster "prod": file_sd: invalid pattern "targets/[.json"`: `
+clusters:
+  - name: prod
+    file_sd:
+      files: ["targets/[.json"]
+`,
+			`cluster "prod": jolokia: invalid mbean "java.lang:type=Memory", expected a pattern in the kafka.connect domain`: `
+clusters:
+  - name: prod
+    urls: [http://connect-1:8083]
+    jolokia:
+      mbeans: ["java.lang:type=Memory"]
+`,
+			`cluster "prod": jolokia: auth.basic: username is required`: `
+clusters:
+  - name: prod
+    urls: [http://connect-1:8083]
+    jolokia:
+      auth:
+        basic: {password: secret}
+`,
+			`cluster "prod": jolokia: invalid scheme "ftp", expected http or https`: `
+clusters:
+  - name: prod
+    urls: [http://connect-1:8083]
+    jolokia:
+      scheme: ftp
+`,
+			`module "jmx": jolokia: invalid path "jolokia", expected an absolute path`: `
+modules:
+  jmx:
+    jolokia:
+      path: jolokia
+`,
+			`cluster "prod": offsets: max_partitions must not be negative, got -1`: `
+clusters:
+  - name: prod
+    urls: [http://connect-1:8083]
+    offsets:
+      max_partitions: -1
+`,
+			`cluster "prod": offsets: stall_thresholds[0]: threshold must be positive, got 0s`: `
+clusters:
+  - name: prod
+    urls: [http://connect-1:8083]
+    offsets:
+      stall_thresholds:
+        - connectors: ["orders-.*"]
+`,
+			`module "prod": topics: reset_interval must not be negative, got -1h0m0s`: `
+modules:
+  prod:
+    topics:
+      reset_interval: -1h
+`,
+			`strimzi: unknown module "strimzi"`: `
+strimzi:
+  enabled: true
+  module: strimzi
+`,
+			`cluster "prod": invalid scheme "tcp" of url "dns+a://connect:8083?scheme=tcp", expected http or https`: `
+clusters:
+  - name: prod
+    urls: ["dns+a://connect:8083?scheme=tcp"]
+`,
+			`dns: invalid resolver "10.0.0.2", expected host:port`: `
+dns:
+  resolver: 10.0.0.2
+`,
+			"poll_interval must be positive, got -1s": `
+poll_interval: -1s
+`,
+		}
+
+		for message, content := range tests {
+			_, err := Load(writeConfigFile(t, content))
+			assert.EqualError(t, err, "Invalid config: "+message)
+		}
+	})
+}
+
+func TestSecret(t *testing.T) {
+	t.Run("Should not print the secret", func(t *testing.T) {
+		secret := Secret("password")
+		assert.Equal(t, "<secret>", fmt.Sprint(secret))
+		assert.Equal(t, "<secret>", fmt.Sprintf("%#v", secret))
+		assert.Equal(t, "{Username:user Password:<secret> PasswordFile:}", fmt.Sprintf("%+v", BasicAuth{Username: "user", Password: secret}))
+	})
+}
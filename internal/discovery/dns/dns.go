@@ -0,0 +1,194 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	applicationError "github.com/Ecube-Labs/kafka-connect-exporter/pkg/application-error"
+	"github.com/Ecube-Labs/kafka-connect-exporter/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultPort = 8083
+
+// The lookups of net.Resolver used by the discoverer
+type Resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// Resolves the dns+srv:// and dns+a:// urls of a cluster to its hosts on every refresh interval.
+// It implements the prometheus.Collector interface to export the number of resolved hosts.
+type Discoverer struct {
+	target   poller.Target
+	names    []dnsName
+	resolver Resolver
+	interval time.Duration
+	timeout  time.Duration
+	desc     *prometheus.Desc
+
+	mu sync.RWMutex
+	// the hosts of every name, kept when a lookup fails
+	hosts map[string][]string
+}
+
+// A DNS name to resolve, e.g. dns+srv://_connect._tcp.example.com or dns+a://connect.example.com:8083?scheme=https
+type dnsName struct {
+	url    string
+	srv    bool
+	host   string
+	port   int
+	scheme string
+}
+
+// Create a resolver that sends queries to the given DNS server, or the resolver of the system when the address is empty
+func NewResolver(address string) Resolver {
+	if address == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+}
+
+// The target of the cluster is the template of the resolved targets, which only differ by host
+func New(resolver Resolver, target poller.Target, urls []string, settings config.DNS) (*Discoverer, error) {
+	discoverer := &Discoverer{
+		target:   target,
+		resolver: resolver,
+		interval: settings.RefreshInterval,
+		timeout:  settings.RefreshInterval,
+		hosts:    map[string][]string{},
+		desc:     prometheus.NewDesc("kafka_connect_dns_resolved_targets", "Number of hosts resolved from the DNS name of the cluster", []string{"cluster", "name"}, nil),
+	}
+	for _, rawURL := range urls {
+		name, err := parseName(rawURL)
+		if err != nil {
+			return nil, applicationError.New(http.StatusBadRequest, fmt.Sprintf("Invalid DNS url %q of cluster %s: %s", rawURL, target.Cluster, err.Error()), "")
+		}
+		discoverer.names = append(discoverer.names, name)
+	}
+	return discoverer, nil
+}
+
+func parseName(rawURL string) (dnsName, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return dnsName{}, err
+	}
+	result := dnsName{url: rawURL, srv: u.Scheme == config.DNSSchemeSRV, host: u.Hostname(), port: defaultPort, scheme: "http"}
+	if scheme := u.Query().Get("scheme"); scheme != "" {
+		result.scheme = scheme
+	}
+	if port := u.Port(); port != "" {
+		if result.port, err = strconv.Atoi(port); err != nil {
+			return dnsName{}, err
+		}
+	}
+	return result, nil
+}
+
+// Resolve the names once, then on every refresh interval in the background until the context is cancelled
+func (d *Discoverer) Start(ctx context.Context) {
+	d.Refresh(ctx)
+
+	go func() {
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				d.Refresh(ctx)
+			}
+		}
+	}()
+}
+
+// Resolve every name. The hosts of a name that cannot be resolved are kept until the next successful lookup.
+func (d *Discoverer) Refresh(ctx context.Context) {
+	for _, name := range d.names {
+		hosts, err := d.resolve(ctx, name)
+		if err != nil {
+			logger.Log("error", fmt.Sprintf("Failed to resolve %s of cluster %s: %s", name.url, d.target.Cluster, err.Error()))
+			continue
+		}
+		d.mu.Lock()
+		d.hosts[name.url] = hosts
+		d.mu.Unlock()
+	}
+}
+
+func (d *Discoverer) resolve(ctx context.Context, name dnsName) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	var hosts []string
+	if name.srv {
+		_, records, err := d.resolver.LookupSRV(ctx, "", "", name.host)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			hosts = append(hosts, d.hostURL(name.scheme, strings.TrimSuffix(record.Target, "."), int(record.Port)))
+		}
+	} else {
+		addresses, err := d.resolver.LookupIPAddr(ctx, name.host)
+		if err != nil {
+			return nil, err
+		}
+		for _, address := range addresses {
+			hosts = append(hosts, d.hostURL(name.scheme, address.IP.String(), name.port))
+		}
+	}
+	sort.Strings(hosts)
+	return hosts, nil
+}
+
+func (d *Discoverer) hostURL(scheme, host string, port int) string {
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(port)))
+}
+
+// The hosts resolved by the latest successful lookup of every name
+func (d *Discoverer) Targets() []poller.Target {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var targets []poller.Target
+	for _, name := range d.names {
+		for _, host := range d.hosts[name.url] {
+			target := d.target
+			target.Host = host
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// Describe is a no-op, because the Collector dynamically allocates metrics.
+func (d *Discoverer) Describe(ch chan<- *prometheus.Desc) {}
+
+func (d *Discoverer) Collect(ch chan<- prometheus.Metric) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, name := range d.names {
+		ch <- prometheus.MustNewConstMetric(d.desc, prometheus.GaugeValue, float64(len(d.hosts[name.url])), d.target.Cluster, name.url)
+	}
+}
@@ -0,0 +1,106 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockResolver struct {
+	mu  sync.Mutex
+	srv map[string][]*net.SRV
+	ip  map[string][]net.IPAddr
+	err error
+}
+
+func (m *mockResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return name, m.srv[name], m.err
+}
+
+func (m *mockResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ip[host], m.err
+}
+
+func hosts(targets []poller.Target) []string {
+	var hosts []string
+	for _, target := range targets {
+		hosts = append(hosts, target.Host)
+	}
+	return hosts
+}
+
+func TestRefresh(t *testing.T) {
+	resolver := &mockResolver{
+		srv: map[string][]*net.SRV{"_connect._tcp.example.com": {
+			{Target: "connect-2.example.com.", Port: 8083},
+			{Target: "connect-1.example.com.", Port: 8083},
+		}},
+		ip: map[string][]net.IPAddr{"connect.example.com": {{IP: net.ParseIP("10.0.0.1")}, {IP: net.ParseIP("fd00::1")}}},
+	}
+	urls := []string{"dns+srv://_connect._tcp.example.com", "dns+a://connect.example.com:8443?scheme=https"}
+
+	t.Run("Should resolve SRV and A records to the hosts of the cluster", func(t *testing.T) {
+		discoverer, err := New(resolver, poller.Target{Cluster: "prod"}, urls, config.DNS{RefreshInterval: time.Minute})
+		assert.Nil(t, err)
+		assert.Empty(t, discoverer.Targets())
+
+		discoverer.Refresh(context.Background())
+
+		targets := discoverer.Targets()
+		assert.Equal(t, []string{
+			"http://connect-1.example.com:8083",
+			"http://connect-2.example.com:8083",
+			"https://10.0.0.1:8443",
+			"https://[fd00::1]:8443",
+		}, hosts(targets))
+		assert.Equal(t, "prod", targets[0].Cluster)
+
+		expected := `
+# HELP kafka_connect_dns_resolved_targets Number of hosts resolved from the DNS name of the cluster
+# TYPE kafka_connect_dns_resolved_targets gauge
+kafka_connect_dns_resolved_targets{cluster="prod",name="dns+a://connect.example.com:8443?scheme=https"} 2
+kafka_connect_dns_resolved_targets{cluster="prod",name="dns+srv://_connect._tcp.example.com"} 2
+`
+		assert.Nil(t, testutil.CollectAndCompare(discoverer, strings.NewReader(expected)))
+	})
+
+	t.Run("Should keep the hosts of a name that cannot be resolved", func(t *testing.T) {
+		discoverer, err := New(resolver, poller.Target{Cluster: "prod"}, urls, config.DNS{RefreshInterval: time.Minute})
+		assert.Nil(t, err)
+		discoverer.Refresh(context.Background())
+
+		failing := &mockResolver{err: errors.New("no such host")}
+		discoverer.resolver = failing
+		discoverer.Refresh(context.Background())
+		assert.Len(t, discoverer.Targets(), 4)
+	})
+
+	t.Run("Should pick up new hosts on the refresh interval", func(t *testing.T) {
+		resolver := &mockResolver{ip: map[string][]net.IPAddr{"connect.example.com": {{IP: net.ParseIP("10.0.0.1")}}}}
+		discoverer, err := New(resolver, poller.Target{Cluster: "prod"}, []string{"dns+a://connect.example.com"}, config.DNS{RefreshInterval: 10 * time.Millisecond})
+		assert.Nil(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		discoverer.Start(ctx)
+		assert.Equal(t, []string{"http://10.0.0.1:8083"}, hosts(discoverer.Targets()))
+
+		resolver.mu.Lock()
+		resolver.ip = map[string][]net.IPAddr{"connect.example.com": {{IP: net.ParseIP("10.0.0.1")}, {IP: net.ParseIP("10.0.0.2")}}}
+		resolver.mu.Unlock()
+		assert.Eventually(t, func() bool { return len(discoverer.Targets()) == 2 }, time.Second, 5*time.Millisecond)
+	})
+}
@@ -0,0 +1,246 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	"github.com/Ecube-Labs/kafka-connect-exporter/pkg/logger"
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v3"
+)
+
+// A group of targets sharing the same labels, as in the file_sd files of Prometheus
+type group struct {
+	// urls of the REST API of the workers, http:// is assumed for host:port
+	Targets []string          `yaml:"targets"`
+	Labels  map[string]string `yaml:"labels"`
+}
+
+// Reads the targets of a cluster from the files matching the glob patterns whenever their directories change,
+// and on every refresh interval in case a change is missed, e.g. on file systems without notifications.
+// It implements the prometheus.Collector interface to export the number of targets of every file.
+type Discoverer struct {
+	target    poller.Target
+	patterns  []string
+	interval  time.Duration
+	desc      *prometheus.Desc
+	validDesc *prometheus.Desc
+
+	mu sync.RWMutex
+	// the targets of every file, replaced as a whole on every refresh
+	files map[string]*fileTargets
+}
+
+type fileTargets struct {
+	targets []poller.Target
+	// whether the latest read of the file succeeded, the targets of the last valid content are kept otherwise
+	valid bool
+}
+
+// The target of the cluster is the template of the targets in the files, which differ by host and labels
+func New(target poller.Target, settings config.FileDiscovery) *Discoverer {
+	return &Discoverer{
+		target:    target,
+		patterns:  settings.Files,
+		interval:  settings.RefreshInterval,
+		files:     map[string]*fileTargets{},
+		desc:      prometheus.NewDesc("kafka_connect_file_sd_targets", "Number of targets read from the file", []string{"cluster", "file"}, nil),
+		validDesc: prometheus.NewDesc("kafka_connect_file_sd_file_valid", "Whether the latest read of the file succeeded (1) or its previous targets are kept (0)", []string{"cluster", "file"}, nil),
+	}
+}
+
+// Read the files once, then on every change of their directories and every refresh interval in the background
+// until the context is cancelled. Without a watcher the files are only read on the refresh interval.
+func (d *Discoverer) Start(ctx context.Context) {
+	d.Refresh()
+
+	watcher, err := d.watch()
+	if err != nil {
+		logger.Log("error", fmt.Sprintf("Failed to watch the files of cluster %s, they are read every %s: %s", d.target.Cluster, d.interval, err.Error()))
+	}
+
+	go func() {
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		// the channels of a missing watcher are nil and never ready
+		var events chan fsnotify.Event
+		var errs chan error
+		if watcher != nil {
+			defer watcher.Close()
+			events, errs = watcher.Events, watcher.Errors
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				d.Refresh()
+			case <-events:
+				d.Refresh()
+			case err := <-errs:
+				logger.Log("error", fmt.Sprintf("Failed to watch the files of cluster %s: %s", d.target.Cluster, err.Error()))
+			}
+		}
+	}()
+}
+
+// Watch the directories of the patterns, which also sees files that are created, renamed or replaced
+// through a symlink, e.g. a mounted ConfigMap. Directories with glob characters are only read on the refresh interval.
+func (d *Discoverer) watch() (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	for _, pattern := range d.patterns {
+		dir := filepath.Dir(pattern)
+		if strings.ContainsAny(dir, `*?[\`) || slices.Contains(watcher.WatchList(), dir) {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+	}
+	return watcher, nil
+}
+
+// Read every file matching the patterns and replace all targets at once, so that a poll never sees a partial reload.
+// A file that cannot be read or parsed keeps its previous targets, e.g. while it is being written.
+// The targets of files that no longer match are dropped.
+// Refresh must not be called concurrently.
+func (d *Discoverer) Refresh() {
+	files := map[string]*fileTargets{}
+	for _, path := range d.paths() {
+		targets, err := d.read(path)
+		if err != nil {
+			logger.Log("error", fmt.Sprintf("Failed to read the targets of cluster %s from %s: %s", d.target.Cluster, path, err.Error()))
+			previous := &fileTargets{}
+			if current, ok := d.files[path]; ok {
+				previous.targets = current.targets
+			}
+			files[path] = previous
+			continue
+		}
+		files[path] = &fileTargets{targets: targets, valid: true}
+	}
+
+	d.mu.Lock()
+	d.files = files
+	d.mu.Unlock()
+}
+
+// The sorted files matching any of the patterns
+func (d *Discoverer) paths() []string {
+	seen := map[string]bool{}
+	var paths []string
+	for _, pattern := range d.patterns {
+		// the patterns are validated with the config
+		matches, _ := filepath.Glob(pattern)
+		for _, path := range matches {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Parse the groups of a JSON or YAML file. A file with an invalid group is rejected as a whole.
+func (d *Discoverer) read(path string) ([]poller.Target, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is valid YAML
+	var groups []group
+	if err := yaml.Unmarshal(content, &groups); err != nil {
+		return nil, err
+	}
+
+	var targets []poller.Target
+	for i, group := range groups {
+		if err := config.ValidateLabels(group.Labels); err != nil {
+			return nil, fmt.Errorf("group %d: %s", i, err.Error())
+		}
+		labels := make(map[string]string, len(d.target.Labels)+len(group.Labels))
+		for name, value := range d.target.Labels {
+			labels[name] = value
+		}
+		for name, value := range group.Labels {
+			labels[name] = value
+		}
+
+		for _, rawURL := range group.Targets {
+			host, err := hostURL(rawURL)
+			if err != nil {
+				return nil, fmt.Errorf("group %d: %s", i, err.Error())
+			}
+			target := d.target
+			target.Host = host
+			target.Labels = labels
+			targets = append(targets, target)
+		}
+	}
+	return targets, nil
+}
+
+func hostURL(target string) (string, error) {
+	host := target
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	u, err := url.Parse(host)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("invalid target %q, expected http(s)://host:port or host:port", target)
+	}
+	return strings.TrimSuffix(host, "/"), nil
+}
+
+// The targets of every file, in the order of the file names
+func (d *Discoverer) Targets() []poller.Target {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	paths := make([]string, 0, len(d.files))
+	for path := range d.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var targets []poller.Target
+	for _, path := range paths {
+		targets = append(targets, d.files[path].targets...)
+	}
+	return targets
+}
+
+// Describe is a no-op, because the Collector dynamically allocates metrics.
+func (d *Discoverer) Describe(ch chan<- *prometheus.Desc) {}
+
+func (d *Discoverer) Collect(ch chan<- prometheus.Metric) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for path, file := range d.files {
+		valid := 0.0
+		if file.valid {
+			valid = 1
+		}
+		ch <- prometheus.MustNewConstMetric(d.desc, prometheus.GaugeValue, float64(len(file.targets)), d.target.Cluster, path)
+		ch <- prometheus.MustNewConstMetric(d.validDesc, prometheus.GaugeValue, valid, d.target.Cluster, path)
+	}
+}
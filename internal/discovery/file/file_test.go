@@ -0,0 +1,141 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func hosts(targets []poller.Target) []string {
+	var hosts []string
+	for _, target := range targets {
+		hosts = append(hosts, target.Host)
+	}
+	return hosts
+}
+
+func TestRefresh(t *testing.T) {
+	cluster := poller.Target{Cluster: "prod", Labels: map[string]string{"env": "prod", "region": "eu"}}
+
+	t.Run("Should read the targets and labels of JSON and YAML files matching the patterns", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "a.json"), `[{"targets": ["connect-1:8083", "https://connect-2:8443/"], "labels": {"rack": "r1", "region": "us"}}]`)
+		writeFile(t, filepath.Join(dir, "b.yml"), "- targets: [http://connect-3:8083]\n")
+		writeFile(t, filepath.Join(dir, "ignored.txt"), "not a target file")
+
+		discoverer := New(cluster, config.FileDiscovery{Files: []string{filepath.Join(dir, "*.json"), filepath.Join(dir, "*.yml")}, RefreshInterval: time.Minute})
+		discoverer.Refresh()
+
+		targets := discoverer.Targets()
+		assert.Equal(t, []string{"http://connect-1:8083", "https://connect-2:8443", "http://connect-3:8083"}, hosts(targets))
+		assert.Equal(t, map[string]string{"env": "prod", "region": "us", "rack": "r1"}, targets[0].Labels)
+		assert.Equal(t, cluster.Labels, targets[2].Labels)
+		assert.Equal(t, "prod", targets[2].Cluster)
+
+		expected := `
+# HELP kafka_connect_file_sd_targets Number of targets read from the file
+# TYPE kafka_connect_file_sd_targets gauge
+kafka_connect_file_sd_targets{cluster="prod",file="` + filepath.Join(dir, "a.json") + `"} 2
+kafka_connect_file_sd_targets{cluster="prod",file="` + filepath.Join(dir, "b.yml") + `"} 1
+`
+		assert.Nil(t, testutil.CollectAndCompare(discoverer, strings.NewReader(expected), "kafka_connect_file_sd_targets"))
+	})
+
+	t.Run("Should keep the previous targets of a file that cannot be parsed", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "targets.json")
+		writeFile(t, path, `[{"targets": ["connect-1:8083"]}]`)
+
+		discoverer := New(cluster, config.FileDiscovery{Files: []string{path}, RefreshInterval: time.Minute})
+		discoverer.Refresh()
+
+		for _, content := range []string{
+			`[{"targets": ["connect-1:8083", "connect-2`,
+			`[{"targets": ["ftp://connect-2:21"]}]`,
+			`[{"targets": ["connect-2:8083"], "labels": {"host": "connect-2"}}]`,
+		} {
+			writeFile(t, path, content)
+			discoverer.Refresh()
+			assert.Equal(t, []string{"http://connect-1:8083"}, hosts(discoverer.Targets()))
+		}
+
+		expected := `
+# HELP kafka_connect_file_sd_file_valid Whether the latest read of the file succeeded (1) or its previous targets are kept (0)
+# TYPE kafka_connect_file_sd_file_valid gauge
+kafka_connect_file_sd_file_valid{cluster="prod",file="` + path + `"} 0
+`
+		assert.Nil(t, testutil.CollectAndCompare(discoverer, strings.NewReader(expected), "kafka_connect_file_sd_file_valid"))
+	})
+
+	t.Run("Should drop the targets of files that are removed", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "a.json"), `[{"targets": ["connect-1:8083"]}]`)
+		writeFile(t, filepath.Join(dir, "b.json"), `[{"targets": ["connect-2:8083"]}]`)
+
+		discoverer := New(cluster, config.FileDiscovery{Files: []string{filepath.Join(dir, "*.json")}, RefreshInterval: time.Minute})
+		discoverer.Refresh()
+		assert.Equal(t, []string{"http://connect-1:8083", "http://connect-2:8083"}, hosts(discoverer.Targets()))
+
+		assert.Nil(t, os.Remove(filepath.Join(dir, "a.json")))
+		discoverer.Refresh()
+		assert.Equal(t, []string{"http://connect-2:8083"}, hosts(discoverer.Targets()))
+	})
+
+	t.Run("Should pick up changed files on the refresh interval", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "targets.yaml")
+		writeFile(t, path, "- targets: [connect-1:8083]\n")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		discoverer := New(cluster, config.FileDiscovery{Files: []string{path}, RefreshInterval: 10 * time.Millisecond})
+		discoverer.Start(ctx)
+		assert.Equal(t, []string{"http://connect-1:8083"}, hosts(discoverer.Targets()))
+
+		// replace the file atomically, like a deployment tool would
+		writeFile(t, path+".tmp", "- targets: [connect-1:8083, connect-2:8083]\n")
+		assert.Nil(t, os.Rename(path+".tmp", path))
+		assert.Eventually(t, func() bool {
+			return len(discoverer.Targets()) == 2
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Should pick up created and changed files without waiting for the refresh interval", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "a.yaml"), "- targets: [connect-1:8083]\n")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		discoverer := New(cluster, config.FileDiscovery{Files: []string{filepath.Join(dir, "*.yaml")}, RefreshInterval: time.Hour})
+		discoverer.Start(ctx)
+		assert.Equal(t, []string{"http://connect-1:8083"}, hosts(discoverer.Targets()))
+
+		writeFile(t, filepath.Join(dir, "b.yaml"), "- targets: [connect-2:8083]\n")
+		assert.Eventually(t, func() bool {
+			return len(discoverer.Targets()) == 2
+		}, time.Second, 10*time.Millisecond)
+
+		writeFile(t, filepath.Join(dir, "a.yaml"), "- targets: [connect-3:8083]\n")
+		assert.Eventually(t, func() bool {
+			return slices.Equal([]string{"http://connect-3:8083", "http://connect-2:8083"}, hosts(discoverer.Targets()))
+		}, time.Second, 10*time.Millisecond)
+	})
+}
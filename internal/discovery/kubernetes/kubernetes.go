@@ -0,0 +1,198 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	applicationError "github.com/Ecube-Labs/kafka-connect-exporter/pkg/application-error"
+	"github.com/Ecube-Labs/kafka-connect-exporter/pkg/logger"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	k8s "k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Discovers the kafka connect workers of a cluster from the pods or the endpoints of services in kubernetes.
+// The objects are watched with informers, so that Targets only reads the local cache.
+type Discoverer struct {
+	target   poller.Target
+	settings config.KubernetesDiscovery
+	selector labels.Selector
+
+	factories      []informers.SharedInformerFactory
+	pods           []corelisters.PodLister
+	services       []corelisters.ServiceLister
+	endpointSlices []discoverylisters.EndpointSliceLister
+	synced         []cache.InformerSynced
+}
+
+// Create a client from the kubeconfig file, or from the service account of the pod when the path is empty
+func NewClient(kubeconfig string) (k8s.Interface, error) {
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, applicationError.New(http.StatusBadRequest, fmt.Sprintf("Failed to load kubernetes config: %s", err.Error()), "")
+	}
+	client, err := k8s.NewForConfig(restConfig)
+	if err != nil {
+		return nil, applicationError.New(http.StatusBadRequest, fmt.Sprintf("Failed to create kubernetes client: %s", err.Error()), "")
+	}
+	return client, nil
+}
+
+// The target of the cluster is the template of the discovered targets, which only differ by host and labels
+func New(client k8s.Interface, target poller.Target, settings config.KubernetesDiscovery) (*Discoverer, error) {
+	selector, err := labels.Parse(settings.LabelSelector)
+	if err != nil {
+		return nil, applicationError.New(http.StatusBadRequest, fmt.Sprintf("Invalid label selector of cluster %s: %s", target.Cluster, err.Error()), "")
+	}
+
+	discoverer := &Discoverer{target: target, settings: settings, selector: selector}
+
+	namespaces := settings.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	for _, namespace := range namespaces {
+		factory := informers.NewSharedInformerFactoryWithOptions(client, 0, informers.WithNamespace(namespace))
+		discoverer.factories = append(discoverer.factories, factory)
+
+		if settings.Role == config.KubernetesRoleService {
+			services := factory.Core().V1().Services()
+			endpointSlices := factory.Discovery().V1().EndpointSlices()
+			discoverer.services = append(discoverer.services, services.Lister())
+			discoverer.endpointSlices = append(discoverer.endpointSlices, endpointSlices.Lister())
+			discoverer.synced = append(discoverer.synced, services.Informer().HasSynced, endpointSlices.Informer().HasSynced)
+			continue
+		}
+		pods := factory.Core().V1().Pods()
+		discoverer.pods = append(discoverer.pods, pods.Lister())
+		discoverer.synced = append(discoverer.synced, pods.Informer().HasSynced)
+	}
+	return discoverer, nil
+}
+
+// Start watching until the context is cancelled and wait for the first list of the objects.
+// Returns false when the context of the wait is done before, the informers keep trying in the background.
+func (d *Discoverer) Start(ctx context.Context, waitCtx context.Context) bool {
+	for _, factory := range d.factories {
+		factory.Start(ctx.Done())
+	}
+	return cache.WaitForCacheSync(waitCtx.Done(), d.synced...)
+}
+
+// The ready workers, sorted by host
+func (d *Discoverer) Targets() []poller.Target {
+	var targets []poller.Target
+	var err error
+	if d.settings.Role == config.KubernetesRoleService {
+		targets, err = d.serviceTargets()
+	} else {
+		targets, err = d.podTargets()
+	}
+	if err != nil {
+		logger.Log("error", fmt.Sprintf("Failed to list kubernetes objects of cluster %s: %s", d.target.Cluster, err.Error()))
+	}
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Host < targets[j].Host })
+	return targets
+}
+
+func (d *Discoverer) podTargets() ([]poller.Target, error) {
+	var targets []poller.Target
+	for _, lister := range d.pods {
+		pods, err := lister.List(d.selector)
+		if err != nil {
+			return targets, err
+		}
+		for _, pod := range pods {
+			if !podReady(pod) {
+				continue
+			}
+			targets = append(targets, d.newTarget(pod.Status.PodIP, map[string]string{"namespace": pod.Namespace, "pod": pod.Name}))
+		}
+	}
+	return targets, nil
+}
+
+func (d *Discoverer) serviceTargets() ([]poller.Target, error) {
+	var targets []poller.Target
+	for i, lister := range d.services {
+		services, err := lister.List(d.selector)
+		if err != nil {
+			return targets, err
+		}
+		for _, service := range services {
+			slices, err := d.endpointSlices[i].EndpointSlices(service.Namespace).List(labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: service.Name}))
+			if err != nil {
+				return targets, err
+			}
+			// a dual-stack service has a slice per address family with the same pods, which are only polled once
+			// with the address of the first family, IPv4 before IPv6
+			sort.Slice(slices, func(i, j int) bool {
+				if slices[i].AddressType != slices[j].AddressType {
+					return slices[i].AddressType < slices[j].AddressType
+				}
+				return slices[i].Name < slices[j].Name
+			})
+			seen := map[string]bool{}
+			for _, slice := range slices {
+				for _, endpoint := range slice.Endpoints {
+					// endpoints without a ready condition are considered ready
+					if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+						continue
+					}
+					labels := map[string]string{"namespace": service.Namespace, "service": service.Name}
+					if endpoint.TargetRef != nil && endpoint.TargetRef.Kind == "Pod" {
+						labels["pod"] = endpoint.TargetRef.Name
+					}
+					for _, address := range endpoint.Addresses {
+						key := address
+						if endpoint.TargetRef != nil {
+							key = endpoint.TargetRef.Kind + "/" + endpoint.TargetRef.Name
+						}
+						if seen[key] {
+							continue
+						}
+						seen[key] = true
+						targets = append(targets, d.newTarget(address, labels))
+					}
+				}
+			}
+		}
+	}
+	return targets, nil
+}
+
+// A target of the cluster with the given address and the static labels of the cluster next to the kubernetes labels
+func (d *Discoverer) newTarget(address string, kubernetesLabels map[string]string) poller.Target {
+	target := d.target
+	target.Host = fmt.Sprintf("%s://%s", d.settings.Scheme, net.JoinHostPort(address, strconv.Itoa(d.settings.Port)))
+	target.Labels = maps.Clone(kubernetesLabels)
+	maps.Copy(target.Labels, d.target.Labels)
+	return target
+}
+
+func podReady(pod *corev1.Pod) bool {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
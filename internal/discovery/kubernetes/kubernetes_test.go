@@ -0,0 +1,140 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newPod(namespace, name, ip string, labels map[string]string, ready bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			PodIP:      ip,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+		},
+	}
+}
+
+func start(t *testing.T, discoverer *Discoverer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	assert.True(t, discoverer.Start(ctx, ctx))
+}
+
+func hosts(targets []poller.Target) []string {
+	var hosts []string
+	for _, target := range targets {
+		hosts = append(hosts, target.Host)
+	}
+	return hosts
+}
+
+func TestPodTargets(t *testing.T) {
+	t.Run("Should discover the ready pods matching the namespaces and label selector", func(t *testing.T) {
+		connect := map[string]string{"app": "connect"}
+		client := fake.NewClientset(
+			newPod("kafka", "connect-0", "10.0.0.1", connect, true),
+			newPod("kafka", "connect-1", "10.0.0.2", connect, false),
+			newPod("kafka", "zookeeper-0", "10.0.0.3", map[string]string{"app": "zookeeper"}, true),
+			newPod("other", "connect-0", "10.0.1.1", connect, true),
+		)
+		target := poller.Target{Cluster: "prod", Labels: map[string]string{"env": "prod"}}
+		settings := config.KubernetesDiscovery{Role: config.KubernetesRolePod, Namespaces: []string{"kafka"}, LabelSelector: "app=connect", Port: 8083, Scheme: "http"}
+
+		discoverer, err := New(client, target, settings)
+		assert.Nil(t, err)
+		start(t, discoverer)
+
+		targets := discoverer.Targets()
+		assert.Equal(t, []poller.Target{{
+			Cluster: "prod",
+			Host:    "http://10.0.0.1:8083",
+			Labels:  map[string]string{"env": "prod", "namespace": "kafka", "pod": "connect-0"},
+		}}, targets)
+
+		_, err = client.CoreV1().Pods("kafka").Create(context.Background(), newPod("kafka", "connect-2", "fd00::1", connect, true), metav1.CreateOptions{})
+		assert.Nil(t, err)
+		assert.Eventually(t, func() bool { return len(discoverer.Targets()) == 2 }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, []string{"http://10.0.0.1:8083", "http://[fd00::1]:8083"}, hosts(discoverer.Targets()))
+
+		assert.Nil(t, client.CoreV1().Pods("kafka").Delete(context.Background(), "connect-0", metav1.DeleteOptions{}))
+		assert.Eventually(t, func() bool { return len(discoverer.Targets()) == 1 }, time.Second, 5*time.Millisecond)
+	})
+}
+
+func TestServiceTargets(t *testing.T) {
+	t.Run("Should discover the ready endpoints of the services matching the label selector", func(t *testing.T) {
+		ready, notReady := true, false
+		client := fake.NewClientset(
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "kafka", Name: "connect", Labels: map[string]string{"app": "connect"}}},
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "kafka", Name: "zookeeper"}},
+			&discoveryv1.EndpointSlice{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "kafka", Name: "connect-abc", Labels: map[string]string{discoveryv1.LabelServiceName: "connect"}},
+				Endpoints: []discoveryv1.Endpoint{
+					{Addresses: []string{"10.0.0.1"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}, TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "connect-0"}},
+					{Addresses: []string{"10.0.0.2"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}},
+				},
+			},
+			&discoveryv1.EndpointSlice{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "kafka", Name: "zookeeper-abc", Labels: map[string]string{discoveryv1.LabelServiceName: "zookeeper"}},
+				Endpoints:  []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.3"}}},
+			},
+		)
+		settings := config.KubernetesDiscovery{Role: config.KubernetesRoleService, LabelSelector: "app=connect", Port: 8443, Scheme: "https"}
+
+		discoverer, err := New(client, poller.Target{Cluster: "prod"}, settings)
+		assert.Nil(t, err)
+		start(t, discoverer)
+
+		assert.Equal(t, []poller.Target{{
+			Cluster: "prod",
+			Host:    "https://10.0.0.1:8443",
+			Labels:  map[string]string{"namespace": "kafka", "service": "connect", "pod": "connect-0"},
+		}}, discoverer.Targets())
+	})
+
+	t.Run("Should discover the pods of a dual-stack service once with their IPv4 address", func(t *testing.T) {
+		connect := &corev1.ObjectReference{Kind: "Pod", Name: "connect-0"}
+		client := fake.NewClientset(
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "kafka", Name: "connect"}},
+			&discoveryv1.EndpointSlice{
+				ObjectMeta:  metav1.ObjectMeta{Namespace: "kafka", Name: "connect-a", Labels: map[string]string{discoveryv1.LabelServiceName: "connect"}},
+				AddressType: discoveryv1.AddressTypeIPv6,
+				Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{"fd00::1"}, TargetRef: connect}},
+			},
+			&discoveryv1.EndpointSlice{
+				ObjectMeta:  metav1.ObjectMeta{Namespace: "kafka", Name: "connect-b", Labels: map[string]string{discoveryv1.LabelServiceName: "connect"}},
+				AddressType: discoveryv1.AddressTypeIPv4,
+				Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.1"}, TargetRef: connect}},
+			},
+		)
+		settings := config.KubernetesDiscovery{Role: config.KubernetesRoleService, Port: 8083, Scheme: "http"}
+
+		discoverer, err := New(client, poller.Target{Cluster: "prod"}, settings)
+		assert.Nil(t, err)
+		start(t, discoverer)
+
+		assert.Equal(t, []string{"http://10.0.0.1:8083"}, hosts(discoverer.Targets()))
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Run("Should return an error for an invalid label selector", func(t *testing.T) {
+		_, err := New(fake.NewClientset(), poller.Target{Cluster: "prod"}, config.KubernetesDiscovery{LabelSelector: "app in (connect"})
+		assert.ErrorContains(t, err, "Invalid label selector of cluster prod")
+	})
+}
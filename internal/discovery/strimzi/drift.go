@@ -0,0 +1,70 @@
+package strimzi
+
+import (
+	"strings"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// state of a connector of a KafkaConnector resource that kafka connect does not know
+const missingState = "missing"
+
+// A struct that implements the prometheus.Collector interface, exporting the drift of the KafkaConnector resources
+type driftCollector struct {
+	discoverer *Discoverer
+	poller     *poller.Poller
+	desc       *prometheus.Desc
+}
+
+// A collector of the drift between the KafkaConnector resources and the connectors in the latest snapshot of the poller
+func (d *Discoverer) DriftCollector(poller *poller.Poller) prometheus.Collector {
+	return &driftCollector{
+		discoverer: d,
+		poller:     poller,
+		desc: prometheus.NewDesc(
+			"kafka_connect_strimzi_connector_drift",
+			"Whether the state of the connector differs from the desired state of its KafkaConnector resource (1) or not (0)",
+			[]string{"namespace", "cluster", "connector", "desired_state", "actual_state"}, nil,
+		),
+	}
+}
+
+// Describe is a no-op, because the Collector dynamically allocates metrics.
+func (c *driftCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect compares the resources with the first healthy host of each cluster. Clusters without a healthy host are skipped,
+// as their actual state is unknown.
+func (c *driftCollector) Collect(ch chan<- prometheus.Metric) {
+	snapshot := c.poller.Snapshot()
+	if snapshot == nil {
+		return
+	}
+	hosts := map[string]*poller.HostSnapshot{}
+	for _, host := range snapshot.ClusterHosts() {
+		hosts[host.Cluster] = host
+	}
+
+	for _, desired := range c.discoverer.desiredConnectors() {
+		host, ok := hosts[clusterName(desired.namespace, desired.cluster)]
+		// connectors excluded by the filters are never collected, so they would always be missing
+		if !ok || !c.discoverer.target.Filter.Match(desired.name) {
+			continue
+		}
+
+		actual := missingState
+		if connector := host.Connectors[desired.name]; connector != nil {
+			// the status of a connector may be unknown on workers without the expand parameters
+			if connector.Status == nil {
+				continue
+			}
+			actual = strings.ToLower(connector.Status.Connector.State)
+		}
+
+		var drift float64
+		if actual != desired.state {
+			drift = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, drift, desired.namespace, desired.cluster, desired.name, desired.state, actual)
+	}
+}
@@ -0,0 +1,185 @@
+package strimzi
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	applicationError "github.com/Ecube-Labs/kafka-connect-exporter/pkg/application-error"
+	"github.com/Ecube-Labs/kafka-connect-exporter/pkg/logger"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var (
+	kafkaConnects   = schema.GroupVersionResource{Group: "kafka.strimzi.io", Version: "v1beta2", Resource: "kafkaconnects"}
+	kafkaConnectors = schema.GroupVersionResource{Group: "kafka.strimzi.io", Version: "v1beta2", Resource: "kafkaconnectors"}
+)
+
+const (
+	// label of a KafkaConnector with the name of its KafkaConnect
+	clusterLabel = "strimzi.io/cluster"
+	restAPIPort  = 8083
+)
+
+// Discovers the REST API of every Strimzi KafkaConnect resource as a cluster named namespace/name,
+// and compares the desired state of the KafkaConnector resources with the state of the connectors.
+type Discoverer struct {
+	target   poller.Target
+	selector labels.Selector
+
+	factories  []dynamicinformer.DynamicSharedInformerFactory
+	connects   []cache.GenericLister
+	connectors []cache.GenericLister
+	synced     []cache.InformerSynced
+}
+
+// The desired state of a KafkaConnector resource
+type connector struct {
+	namespace string
+	cluster   string
+	name      string
+	state     string
+}
+
+// Create a dynamic client from the kubeconfig file, or from the service account of the pod when the path is empty
+func NewClient(kubeconfig string) (dynamic.Interface, error) {
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, applicationError.New(http.StatusBadRequest, fmt.Sprintf("Failed to load kubernetes config: %s", err.Error()), "")
+	}
+	client, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, applicationError.New(http.StatusBadRequest, fmt.Sprintf("Failed to create kubernetes client: %s", err.Error()), "")
+	}
+	return client, nil
+}
+
+// The target is the template of the discovered targets, with the settings of the module
+func New(client dynamic.Interface, target poller.Target, settings config.Strimzi) (*Discoverer, error) {
+	selector, err := labels.Parse(settings.LabelSelector)
+	if err != nil {
+		return nil, applicationError.New(http.StatusBadRequest, fmt.Sprintf("Invalid label selector of strimzi: %s", err.Error()), "")
+	}
+
+	discoverer := &Discoverer{target: target, selector: selector}
+
+	namespaces := settings.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	for _, namespace := range namespaces {
+		factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(client, 0, namespace, nil)
+		discoverer.factories = append(discoverer.factories, factory)
+
+		connects := factory.ForResource(kafkaConnects)
+		connectors := factory.ForResource(kafkaConnectors)
+		discoverer.connects = append(discoverer.connects, connects.Lister())
+		discoverer.connectors = append(discoverer.connectors, connectors.Lister())
+		discoverer.synced = append(discoverer.synced, connects.Informer().HasSynced, connectors.Informer().HasSynced)
+	}
+	return discoverer, nil
+}
+
+// Start watching until the context is cancelled and wait for the first list of the resources.
+// Returns false when the context of the wait is done before, the informers keep trying in the background.
+func (d *Discoverer) Start(ctx context.Context, waitCtx context.Context) bool {
+	for _, factory := range d.factories {
+		factory.Start(ctx.Done())
+	}
+	return cache.WaitForCacheSync(waitCtx.Done(), d.synced...)
+}
+
+// The REST API of every selected KafkaConnect resource, sorted by host
+func (d *Discoverer) Targets() []poller.Target {
+	var targets []poller.Target
+	for _, lister := range d.connects {
+		objects, err := lister.List(d.selector)
+		if err != nil {
+			logger.Log("error", fmt.Sprintf("Failed to list strimzi KafkaConnect resources: %s", err.Error()))
+			continue
+		}
+		for _, object := range objects {
+			connect, ok := object.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+			target := d.target
+			target.Cluster = clusterName(connect.GetNamespace(), connect.GetName())
+			target.Host = restAPIURL(connect)
+			target.Labels = map[string]string{"namespace": connect.GetNamespace()}
+			maps.Copy(target.Labels, d.target.Labels)
+			targets = append(targets, target)
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Host < targets[j].Host })
+	return targets
+}
+
+// The KafkaConnector resources of the discovered clusters
+func (d *Discoverer) desiredConnectors() []connector {
+	clusters := map[string]bool{}
+	for _, target := range d.Targets() {
+		clusters[target.Cluster] = true
+	}
+
+	var desired []connector
+	for _, lister := range d.connectors {
+		objects, err := lister.List(labels.Everything())
+		if err != nil {
+			logger.Log("error", fmt.Sprintf("Failed to list strimzi KafkaConnector resources: %s", err.Error()))
+			continue
+		}
+		for _, object := range objects {
+			resource, ok := object.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+			cluster := resource.GetLabels()[clusterLabel]
+			if !clusters[clusterName(resource.GetNamespace(), cluster)] {
+				continue
+			}
+			desired = append(desired, connector{
+				namespace: resource.GetNamespace(),
+				cluster:   cluster,
+				name:      resource.GetName(),
+				state:     desiredState(resource),
+			})
+		}
+	}
+	return desired
+}
+
+func clusterName(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// The url in the status of the resource, or the url of the REST API service that strimzi creates
+func restAPIURL(connect *unstructured.Unstructured) string {
+	if url, ok, _ := unstructured.NestedString(connect.Object, "status", "url"); ok && url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return fmt.Sprintf("http://%s-connect-api.%s.svc:%d", connect.GetName(), connect.GetNamespace(), restAPIPort)
+}
+
+// The state of the spec, or the deprecated pause flag of older strimzi versions
+func desiredState(resource *unstructured.Unstructured) string {
+	if state, ok, _ := unstructured.NestedString(resource.Object, "spec", "state"); ok && state != "" {
+		return strings.ToLower(state)
+	}
+	if pause, ok, _ := unstructured.NestedBool(resource.Object, "spec", "pause"); ok && pause {
+		return "paused"
+	}
+	return "running"
+}
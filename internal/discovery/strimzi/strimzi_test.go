@@ -0,0 +1,128 @@
+package strimzi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+type mockRoundTripper struct {
+	roundTripFunc func(req *http.Request) (*http.Response, error)
+}
+
+func (m *mockRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return m.roundTripFunc(req)
+}
+
+func newKafkaConnect(namespace, name, url string, labels map[string]any) *unstructured.Unstructured {
+	object := map[string]any{
+		"apiVersion": "kafka.strimzi.io/v1beta2",
+		"kind":       "KafkaConnect",
+		"metadata":   map[string]any{"namespace": namespace, "name": name, "labels": labels},
+	}
+	if url != "" {
+		object["status"] = map[string]any{"url": url}
+	}
+	return &unstructured.Unstructured{Object: object}
+}
+
+func newKafkaConnector(namespace, cluster, name string, spec map[string]any) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "kafka.strimzi.io/v1beta2",
+		"kind":       "KafkaConnector",
+		"metadata":   map[string]any{"namespace": namespace, "name": name, "labels": map[string]any{clusterLabel: cluster}},
+		"spec":       spec,
+	}}
+}
+
+func newDiscoverer(t *testing.T, target poller.Target, settings config.Strimzi, objects ...runtime.Object) *Discoverer {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		kafkaConnects:   "KafkaConnectList",
+		kafkaConnectors: "KafkaConnectorList",
+	}, objects...)
+
+	discoverer, err := New(client, target, settings)
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	assert.True(t, discoverer.Start(ctx, ctx))
+	return discoverer
+}
+
+func TestTargets(t *testing.T) {
+	t.Run("Should discover the REST API of the selected KafkaConnect resources", func(t *testing.T) {
+		discoverer := newDiscoverer(t, poller.Target{Labels: map[string]string{"env": "prod"}}, config.Strimzi{LabelSelector: "team=data"},
+			newKafkaConnect("kafka", "connect-a", "http://connect-a-connect-api.kafka.svc:8083/", map[string]any{"team": "data"}),
+			newKafkaConnect("kafka", "connect-b", "", map[string]any{"team": "data"}),
+			newKafkaConnect("kafka", "connect-c", "", map[string]any{"team": "web"}),
+		)
+
+		assert.Equal(t, []poller.Target{
+			{Cluster: "kafka/connect-a", Host: "http://connect-a-connect-api.kafka.svc:8083", Labels: map[string]string{"env": "prod", "namespace": "kafka"}},
+			{Cluster: "kafka/connect-b", Host: "http://connect-b-connect-api.kafka.svc:8083", Labels: map[string]string{"env": "prod", "namespace": "kafka"}},
+		}, discoverer.Targets())
+	})
+}
+
+func TestDriftCollector(t *testing.T) {
+	t.Run("Should compare the desired state of the KafkaConnector resources with the connectors", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				if req.URL.Path == "/connectors" {
+					response.Write([]byte(`{
+						"running": {"status": {"name": "running", "connector": {"state": "RUNNING"}}},
+						"paused": {"status": {"name": "paused", "connector": {"state": "RUNNING"}}},
+						"legacy-paused": {"status": {"name": "legacy-paused", "connector": {"state": "PAUSED"}}},
+						"stopped": {"status": {"name": "stopped", "connector": {"state": "STOPPED"}}}
+					}`))
+				} else {
+					response.Write([]byte(`[]`))
+				}
+				return response.Result(), nil
+			},
+		}
+		filter, err := collector.NewFilter(nil, []string{"ignored"})
+		assert.Nil(t, err)
+		target := poller.Target{Collector: collector.New(&http.Client{Transport: roundTripper}), Filter: filter}
+
+		discoverer := newDiscoverer(t, target, config.Strimzi{},
+			newKafkaConnect("kafka", "connect", "", nil),
+			newKafkaConnector("kafka", "connect", "running", map[string]any{"state": "running"}),
+			newKafkaConnector("kafka", "connect", "paused", map[string]any{"state": "paused"}),
+			newKafkaConnector("kafka", "connect", "legacy-paused", map[string]any{"pause": true}),
+			newKafkaConnector("kafka", "connect", "stopped", map[string]any{"state": "stopped"}),
+			newKafkaConnector("kafka", "connect", "missing", map[string]any{}),
+			newKafkaConnector("kafka", "connect", "ignored", map[string]any{}),
+			newKafkaConnector("kafka", "unknown-connect", "other", map[string]any{}),
+		)
+		p := poller.New(nil, time.Minute)
+		p.AddDiscoverer(discoverer)
+		p.Poll()
+
+		expected := `
+# HELP kafka_connect_strimzi_connector_drift Whether the state of the connector differs from the desired state of its KafkaConnector resource (1) or not (0)
+# TYPE kafka_connect_strimzi_connector_drift gauge
+kafka_connect_strimzi_connector_drift{actual_state="missing",cluster="connect",connector="missing",desired_state="running",namespace="kafka"} 1
+kafka_connect_strimzi_connector_drift{actual_state="paused",cluster="connect",connector="legacy-paused",desired_state="paused",namespace="kafka"} 0
+kafka_connect_strimzi_connector_drift{actual_state="running",cluster="connect",connector="paused",desired_state="paused",namespace="kafka"} 1
+kafka_connect_strimzi_connector_drift{actual_state="running",cluster="connect",connector="running",desired_state="running",namespace="kafka"} 0
+kafka_connect_strimzi_connector_drift{actual_state="stopped",cluster="connect",connector="stopped",desired_state="stopped",namespace="kafka"} 0
+`
+		assert.Nil(t, testutil.CollectAndCompare(discoverer.DriftCollector(p), strings.NewReader(expected)))
+	})
+}
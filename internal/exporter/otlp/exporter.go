@@ -0,0 +1,190 @@
+package otlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	applicationError "github.com/Ecube-Labs/kafka-connect-exporter/pkg/application-error"
+	"github.com/Ecube-Labs/kafka-connect-exporter/pkg/logger"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+const (
+	serviceName = "kafka-connect-exporter"
+	meterName   = "github.com/Ecube-Labs/kafka-connect-exporter"
+)
+
+// Pushes the metrics of the latest snapshot of the poller to an OpenTelemetry collector on every interval.
+// Every cluster has its own meter provider, so that its name and static labels are sent as resource attributes.
+// Providers are created for the configured clusters on start and for discovered clusters, e.g. of strimzi,
+// when they appear in a snapshot.
+type Exporter struct {
+	ctx      context.Context
+	settings config.OTLP
+	poller   *poller.Poller
+	// the configured clusters by name
+	clusters  map[string]config.Cluster
+	mu        sync.Mutex
+	providers map[string]*meterProvider
+}
+
+// The meter provider of a cluster and its instruments
+type meterProvider struct {
+	*sdkmetric.MeterProvider
+	instruments *instruments
+}
+
+func New(ctx context.Context, settings config.OTLP, clusters []config.Cluster, poller *poller.Poller) (*Exporter, error) {
+	exporter := &Exporter{
+		ctx:       ctx,
+		settings:  settings,
+		poller:    poller,
+		clusters:  make(map[string]config.Cluster, len(clusters)),
+		providers: map[string]*meterProvider{},
+	}
+	for _, cluster := range clusters {
+		exporter.clusters[cluster.Name] = cluster
+		if err := exporter.addProvider(cluster); err != nil {
+			return nil, err
+		}
+	}
+	poller.AddListener(exporter.syncClusters)
+	return exporter, nil
+}
+
+// Push the pending metrics and stop the meter providers
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var errs []error
+	for _, provider := range e.providers {
+		errs = append(errs, provider.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Add a provider for every discovered cluster of the snapshot, stop the providers of discovered clusters that are gone
+// and register the gauges of new MBean attributes
+func (e *Exporter) syncClusters(snapshot *poller.Snapshot) {
+	current := map[string]bool{}
+	for _, host := range snapshot.Hosts {
+		current[host.Cluster] = true
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for cluster := range current {
+		if _, ok := e.providers[cluster]; ok {
+			continue
+		}
+		if err := e.addProvider(config.Cluster{Name: cluster}); err != nil {
+			logger.Log("error", applicationError.UnWrap(err).Stack)
+		}
+	}
+	for cluster, provider := range e.providers {
+		if _, configured := e.clusters[cluster]; configured || current[cluster] {
+			if err := provider.instruments.registerJMX(snapshot); err != nil {
+				logger.Log("error", applicationError.UnWrap(err).Stack)
+			}
+			continue
+		}
+		delete(e.providers, cluster)
+		// pushing the last metrics may take up to the timeout, which must not delay the poller
+		go func() {
+			if err := provider.Shutdown(e.ctx); err != nil {
+				logger.Log("error", applicationError.UnWrap(err).Stack)
+			}
+		}()
+	}
+}
+
+func (e *Exporter) addProvider(cluster config.Cluster) error {
+	metricExporter, err := newMetricExporter(e.ctx, e.settings)
+	if err != nil {
+		return applicationError.New(http.StatusBadRequest, fmt.Sprintf("Failed to create otlp exporter: %s", err.Error()), "")
+	}
+	reader := sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(e.settings.Interval), sdkmetric.WithTimeout(e.settings.Timeout))
+	provider, err := newMeterProvider(cluster, e.settings.ResourceAttributes, reader, e.poller)
+	if err != nil {
+		return err
+	}
+	e.providers[cluster.Name] = provider
+	return nil
+}
+
+func newMetricExporter(ctx context.Context, settings config.OTLP) (sdkmetric.Exporter, error) {
+	headers := make(map[string]string, len(settings.Headers))
+	for name, value := range settings.Headers {
+		headers[name] = string(value)
+	}
+
+	if settings.Protocol == config.OTLPProtocolHTTP {
+		options := []otlpmetrichttp.Option{otlpmetrichttp.WithTimeout(settings.Timeout)}
+		if settings.Endpoint != "" {
+			if strings.Contains(settings.Endpoint, "://") {
+				options = append(options, otlpmetrichttp.WithEndpointURL(settings.Endpoint))
+			} else {
+				options = append(options, otlpmetrichttp.WithEndpoint(settings.Endpoint))
+			}
+		}
+		if settings.Insecure {
+			options = append(options, otlpmetrichttp.WithInsecure())
+		}
+		if len(headers) > 0 {
+			options = append(options, otlpmetrichttp.WithHeaders(headers))
+		}
+		return otlpmetrichttp.New(ctx, options...)
+	}
+
+	options := []otlpmetricgrpc.Option{otlpmetricgrpc.WithTimeout(settings.Timeout)}
+	if settings.Endpoint != "" {
+		if strings.Contains(settings.Endpoint, "://") {
+			options = append(options, otlpmetricgrpc.WithEndpointURL(settings.Endpoint))
+		} else {
+			options = append(options, otlpmetricgrpc.WithEndpoint(settings.Endpoint))
+		}
+	}
+	if settings.Insecure {
+		options = append(options, otlpmetricgrpc.WithInsecure())
+	}
+	if len(headers) > 0 {
+		options = append(options, otlpmetricgrpc.WithHeaders(headers))
+	}
+	return otlpmetricgrpc.New(ctx, options...)
+}
+
+func newMeterProvider(cluster config.Cluster, resourceAttributes map[string]string, reader sdkmetric.Reader, poller *poller.Poller) (*meterProvider, error) {
+	attributes := []attribute.KeyValue{
+		attribute.String("service.name", serviceName),
+		attribute.String("kafka_connect.cluster", cluster.Name),
+	}
+	for name, value := range resourceAttributes {
+		attributes = append(attributes, attribute.String(name, value))
+	}
+	for name, value := range cluster.Labels {
+		attributes = append(attributes, attribute.String(name, value))
+	}
+
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithResource(resource.NewSchemaless(attributes...)),
+		sdkmetric.WithReader(reader),
+	)
+	instruments, err := registerInstruments(provider.Meter(meterName), cluster, poller)
+	if err != nil {
+		// stops the reader and the exporter of the provider
+		provider.Shutdown(context.Background())
+		return nil, applicationError.New(http.StatusInternalServerError, fmt.Sprintf("Failed to register otlp instruments: %s", err.Error()), "")
+	}
+	return &meterProvider{MeterProvider: provider, instruments: instruments}, nil
+}
@@ -0,0 +1,285 @@
+package otlp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+type mockRoundTripper struct {
+	roundTripFunc func(req *http.Request) (*http.Response, error)
+}
+
+func (m *mockRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return m.roundTripFunc(req)
+}
+
+// A polled poller with a prod cluster of a healthy host, a failing host and a host with the labels of a discovered pod,
+// and a staging cluster
+func newPoller() *poller.Poller {
+	p := newUnpolledPoller()
+	p.Poll()
+	return p
+}
+
+func newUnpolledPoller() *poller.Poller {
+	roundTripper := &mockRoundTripper{
+		roundTripFunc: func(req *http.Request) (*http.Response, error) {
+			response := httptest.NewRecorder()
+			switch {
+			case req.URL.Host == "connect-2":
+				response.WriteHeader(http.StatusInternalServerError)
+			case req.URL.Path == "/":
+				response.Write([]byte(`{"version": "3.7.0", "commit": "2ae524ed625438c5", "kafka_cluster_id": "I4ZmrWqfT2e-upky_4fdPA"}`))
+			case req.URL.Path == "/connectors":
+				response.Write([]byte(`{"connector1": {"status": {"name": "connector1", "connector": {"state": "RUNNING"}, "tasks": [{"id": 0, "state": "RUNNING", "worker_id": "worker-1"}, {"id": 1, "state": "FAILED", "worker_id": "worker-2", "trace": "java.lang.IllegalStateException: boom"}]}}}`))
+			default:
+				response.Write([]byte(`[]`))
+			}
+			return response.Result(), nil
+		},
+	}
+	c := collector.New(&http.Client{Transport: roundTripper})
+	return poller.New([]poller.Target{
+		{Cluster: "prod", Host: "http://connect-1", Collector: c},
+		{Cluster: "prod", Host: "http://connect-2", Collector: c},
+		{Cluster: "prod", Host: "http://connect-3", Collector: c, Labels: map[string]string{"env": "production", "pod": "connect-3"}},
+		{Cluster: "staging", Host: "http://connect-staging", Collector: c},
+	}, time.Minute)
+}
+
+func collect(t *testing.T, reader sdkmetric.Reader) (metricdata.ResourceMetrics, map[string]metricdata.Aggregation) {
+	var resourceMetrics metricdata.ResourceMetrics
+	assert.Nil(t, reader.Collect(context.Background(), &resourceMetrics))
+	metrics := map[string]metricdata.Aggregation{}
+	for _, scope := range resourceMetrics.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+	return resourceMetrics, metrics
+}
+
+// The value of the data point with the given attributes
+func value(t *testing.T, data metricdata.Aggregation, attributes ...attribute.KeyValue) int64 {
+	set := attribute.NewSet(attributes...)
+	gauge, ok := data.(metricdata.Gauge[int64])
+	if !ok {
+		t.Fatalf("unexpected aggregation %T", data)
+	}
+	for _, point := range gauge.DataPoints {
+		if point.Attributes.Equals(&set) {
+			return point.Value
+		}
+	}
+	t.Fatalf("no data point with attributes %v", attributes)
+	return 0
+}
+
+// The value of the float data point with the given attributes
+func floatValue(t *testing.T, data metricdata.Aggregation, attributes ...attribute.KeyValue) float64 {
+	set := attribute.NewSet(attributes...)
+	gauge, ok := data.(metricdata.Gauge[float64])
+	if !ok {
+		t.Fatalf("unexpected aggregation %T", data)
+	}
+	for _, point := range gauge.DataPoints {
+		if point.Attributes.Equals(&set) {
+			return point.Value
+		}
+	}
+	t.Fatalf("no data point with attributes %v", attributes)
+	return 0
+}
+
+// A poller of a prod cluster with a host whose offsets, topics and MBeans are read
+func newExtendedPoller() *poller.Poller {
+	roundTripper := &mockRoundTripper{
+		roundTripFunc: func(req *http.Request) (*http.Response, error) {
+			response := httptest.NewRecorder()
+			switch {
+			case req.URL.Port() == "8778":
+				response.Write([]byte(`[{"status": 200, "value": {"kafka.connect:connector=orders-sink,task=0,type=sink-task-metrics": {"sink-record-read-rate": 12.5}}}]`))
+			case req.URL.Path == "/connectors":
+				response.Write([]byte(`{"orders-sink": {"status": {"name": "orders-sink", "connector": {"state": "RUNNING"}, "tasks": [{"id": 0, "state": "RUNNING", "worker_id": "worker-1"}]}}}`))
+			case req.URL.Path == "/connectors/orders-sink/offsets":
+				response.Write([]byte(`{"offsets": [{"partition": {"kafka_topic": "orders", "kafka_partition": 1}, "offset": {"kafka_offset": 42}}]}`))
+			case req.URL.Path == "/connectors/orders-sink/topics":
+				response.Write([]byte(`{"orders-sink": {"topics": ["orders"]}}`))
+			default:
+				response.Write([]byte(`[]`))
+			}
+			return response.Result(), nil
+		},
+	}
+	c := collector.New(&http.Client{Transport: roundTripper})
+	return poller.New([]poller.Target{{
+		Cluster:          "prod",
+		Host:             "http://connect-1:8083",
+		Collector:        c,
+		JolokiaCollector: c,
+		Jolokia:          &config.Jolokia{Port: 8778, Path: "/jolokia", MBeans: []string{"kafka.connect:type=sink-task-metrics,*"}},
+		Offsets:          &config.Offsets{MaxPartitions: 100, StallThreshold: time.Hour},
+		Topics:           &config.Topics{},
+	}}, time.Minute)
+}
+
+func TestNewMeterProvider(t *testing.T) {
+	t.Run("Should observe the hosts of the cluster with the cluster as resource attributes", func(t *testing.T) {
+		reader := sdkmetric.NewManualReader()
+		cluster := config.Cluster{Name: "prod", Labels: map[string]string{"env": "production"}}
+		provider, err := newMeterProvider(cluster, map[string]string{"deployment.environment": "eu"}, reader, newPoller())
+		assert.Nil(t, err)
+		t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+		resourceMetrics, metrics := collect(t, reader)
+
+		for key, expected := range map[attribute.Key]string{"service.name": "kafka-connect-exporter", "kafka_connect.cluster": "prod", "env": "production", "deployment.environment": "eu"} {
+			actual, ok := resourceMetrics.Resource.Set().Value(key)
+			assert.True(t, ok)
+			assert.Equal(t, expected, actual.AsString())
+		}
+
+		host1 := attribute.String("host", "http://connect-1")
+		connector := attribute.String("connector", "connector1")
+		assert.Equal(t, int64(1), value(t, metrics["kafka_connect_up"], host1))
+		assert.Equal(t, int64(0), value(t, metrics["kafka_connect_up"], attribute.String("host", "http://connect-2")))
+		assert.Len(t, metrics["kafka_connect_up"].(metricdata.Gauge[int64]).DataPoints, 3)
+		// labels of discovered hosts are attributes of their data points, unless they are static labels of the cluster
+		assert.Equal(t, int64(1), value(t, metrics["kafka_connect_up"], attribute.String("host", "http://connect-3"), attribute.String("pod", "connect-3")))
+		assert.Equal(t, int64(2), value(t, metrics["kafka_connect_connector_task_total"], attribute.String("host", "http://connect-3"), attribute.String("pod", "connect-3"), connector))
+		assert.Equal(t, int64(1), value(t, metrics["kafka_connect_connector_total"], host1))
+		assert.Equal(t, int64(1), value(t, metrics["kafka_connect_worker_info"], host1, attribute.String("version", "3.7.0"), attribute.String("commit", "2ae524ed625438c5"), attribute.String("kafka_cluster_id", "I4ZmrWqfT2e-upky_4fdPA")))
+		assert.Equal(t, int64(1), value(t, metrics["kafka_connect_connector_running_total"], host1, connector))
+		assert.Equal(t, int64(1), value(t, metrics["kafka_connect_connector_failed_total"], host1, connector))
+		assert.Equal(t, int64(2), value(t, metrics["kafka_connect_connector_task_total"], host1, connector))
+		assert.Equal(t, int64(1), value(t, metrics["kafka_connect_connector_status"], host1, connector, attribute.String("status", "RUNNING")))
+		assert.Equal(t, int64(1), value(t, metrics["kafka_connect_task_failed"], host1, connector, attribute.String("task", "1"), attribute.String("worker_id", "worker-2"), attribute.String("exception", "java.lang.IllegalStateException")))
+		assert.Equal(t, int64(0), value(t, metrics["kafka_connect_task_state"], host1, connector, attribute.String("task", "0"), attribute.String("worker_id", "worker-1"), attribute.String("state", "FAILED")))
+		assert.Equal(t, int64(1), value(t, metrics["kafka_connect_worker_tasks"], host1, attribute.String("worker_id", "worker-2"), attribute.String("state", "FAILED")))
+		assert.Equal(t, int64(0), value(t, metrics["kafka_connect_worker_tasks"], host1, attribute.String("worker_id", "worker-2"), attribute.String("state", "RUNNING")))
+
+		rebalances := metrics["kafka_connect_rebalances_total"].(metricdata.Sum[int64])
+		assert.True(t, rebalances.IsMonotonic)
+		assert.Len(t, rebalances.DataPoints, 3)
+
+		scrapeErrors := metrics["kafka_connect_scrape_errors_total"].(metricdata.Sum[int64])
+		assert.True(t, scrapeErrors.IsMonotonic)
+		assert.Len(t, scrapeErrors.DataPoints, 3*len(collector.FailureKinds))
+	})
+
+	t.Run("Should observe the offsets, topics and MBean attributes of the hosts", func(t *testing.T) {
+		reader := sdkmetric.NewManualReader()
+		p := newExtendedPoller()
+		p.Poll()
+		provider, err := newMeterProvider(config.Cluster{Name: "prod"}, nil, reader, p)
+		assert.Nil(t, err)
+		t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+		_, metrics := collect(t, reader)
+
+		host := attribute.String("host", "http://connect-1:8083")
+		connector := attribute.String("connector", "orders-sink")
+		assert.Contains(t, metrics, "kafka_connect_snapshot_age_seconds")
+		assert.Greater(t, floatValue(t, metrics["kafka_connect_last_successful_poll_timestamp_seconds"], host), float64(0))
+		assert.Equal(t, int64(1), value(t, metrics["kafka_connect_jolokia_up"], host))
+		assert.Equal(t, 12.5, floatValue(t, metrics["kafka_connect_sink_task_metrics_sink_record_read_rate"], host, connector, attribute.String("task", "0")))
+		assert.Equal(t, 42.0, floatValue(t, metrics["kafka_connect_sink_partition_offset"], host, connector, attribute.String("topic", "orders"), attribute.String("partition", "1")))
+		assert.Equal(t, int64(1), value(t, metrics["kafka_connect_connector_offset_partitions"], host, connector))
+		assert.Equal(t, int64(0), value(t, metrics["kafka_connect_connector_stalled"], host, connector))
+		assert.Equal(t, 0.0, floatValue(t, metrics["kafka_connect_connector_seconds_since_progress"], host, connector))
+		assert.Equal(t, int64(1), value(t, metrics["kafka_connect_connector_topic_info"], host, connector, attribute.String("topic", "orders")))
+	})
+
+	t.Run("Should observe MBean attributes that appear after the provider was created", func(t *testing.T) {
+		reader := sdkmetric.NewManualReader()
+		p := newExtendedPoller()
+		provider, err := newMeterProvider(config.Cluster{Name: "prod"}, nil, reader, p)
+		assert.Nil(t, err)
+		t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+		p.Poll()
+		assert.Nil(t, provider.instruments.registerJMX(p.Snapshot()))
+		// registering the same attributes again is a no-op
+		assert.Nil(t, provider.instruments.registerJMX(p.Snapshot()))
+
+		_, metrics := collect(t, reader)
+		assert.Equal(t, 12.5, floatValue(t, metrics["kafka_connect_sink_task_metrics_sink_record_read_rate"],
+			attribute.String("host", "http://connect-1:8083"), attribute.String("connector", "orders-sink"), attribute.String("task", "0")))
+	})
+
+	t.Run("Should not observe anything before the first poll", func(t *testing.T) {
+		reader := sdkmetric.NewManualReader()
+		provider, err := newMeterProvider(config.Cluster{Name: "prod"}, nil, reader, poller.New(nil, time.Minute))
+		assert.Nil(t, err)
+		t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+		_, metrics := collect(t, reader)
+		assert.Empty(t, metrics)
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Run("Should push the metrics of every cluster over http", func(t *testing.T) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/metrics", r.URL.Path)
+			assert.Equal(t, "secret", r.Header.Get("X-Api-Key"))
+			requests.Add(1)
+		}))
+		t.Cleanup(server.Close)
+
+		settings := config.OTLP{
+			Protocol: config.OTLPProtocolHTTP,
+			Endpoint: server.URL,
+			Insecure: true,
+			Headers:  map[string]config.Secret{"X-Api-Key": "secret"},
+			Interval: time.Hour,
+			Timeout:  time.Second,
+		}
+		exporter, err := New(context.Background(), settings, []config.Cluster{{Name: "prod"}, {Name: "staging"}}, newPoller())
+		assert.Nil(t, err)
+
+		assert.Nil(t, exporter.Shutdown(context.Background()))
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("Should push the metrics of clusters that are only discovered", func(t *testing.T) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+		}))
+		t.Cleanup(server.Close)
+
+		settings := config.OTLP{Protocol: config.OTLPProtocolHTTP, Endpoint: server.URL, Insecure: true, Interval: time.Hour, Timeout: time.Second}
+		p := newUnpolledPoller()
+		exporter, err := New(context.Background(), settings, []config.Cluster{{Name: "prod"}}, p)
+		assert.Nil(t, err)
+		assert.Len(t, exporter.providers, 1)
+
+		p.Poll()
+		assert.Len(t, exporter.providers, 2)
+		assert.Contains(t, exporter.providers, "staging")
+
+		assert.Nil(t, exporter.Shutdown(context.Background()))
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("Should create a grpc exporter", func(t *testing.T) {
+		settings := config.OTLP{Protocol: config.OTLPProtocolGRPC, Endpoint: "localhost:4317", Insecure: true, Interval: time.Hour, Timeout: time.Second}
+		exporter, err := New(context.Background(), settings, []config.Cluster{{Name: "prod"}}, poller.New(nil, time.Minute))
+		assert.Nil(t, err)
+		assert.Len(t, exporter.providers, 1)
+	})
+}
@@ -0,0 +1,335 @@
+package otlp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/states"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// The same metrics as the prometheus exporter, so that dashboards work with both outputs
+type instruments struct {
+	snapshotAge      metric.Float64ObservableGauge
+	lastSuccess      metric.Float64ObservableGauge
+	up               metric.Int64ObservableGauge
+	scrapeDuration   metric.Float64ObservableGauge
+	scrapeErrors     metric.Int64ObservableCounter
+	connectorCount   metric.Int64ObservableGauge
+	filtered         metric.Int64ObservableGauge
+	connectorStatus  metric.Int64ObservableGauge
+	connectorInfo    metric.Int64ObservableGauge
+	running          metric.Int64ObservableGauge
+	failed           metric.Int64ObservableGauge
+	paused           metric.Int64ObservableGauge
+	unassigned       metric.Int64ObservableGauge
+	taskCount        metric.Int64ObservableGauge
+	taskState        metric.Int64ObservableGauge
+	taskFailed       metric.Int64ObservableGauge
+	workerInfo       metric.Int64ObservableGauge
+	workerConnectors metric.Int64ObservableGauge
+	workerTasks      metric.Int64ObservableGauge
+	rebalances       metric.Int64ObservableCounter
+	jolokiaUp        metric.Int64ObservableGauge
+	sinkOffset       metric.Float64ObservableGauge
+	sourceOffset     metric.Float64ObservableGauge
+	offsetPartitions metric.Int64ObservableGauge
+	sinceProgress    metric.Float64ObservableGauge
+	stalled          metric.Int64ObservableGauge
+	topicInfo        metric.Int64ObservableGauge
+	// the static labels of the cluster, which are resource attributes
+	clusterLabels map[string]string
+	cluster       string
+	meter         metric.Meter
+	poller        *poller.Poller
+	// the names of the registered gauges of MBean attributes
+	jmx map[string]bool
+}
+
+// Register the instruments with a callback observing the hosts of the cluster in the latest snapshot of the poller
+func registerInstruments(meter metric.Meter, cluster config.Cluster, poller *poller.Poller) (*instruments, error) {
+	i := &instruments{clusterLabels: cluster.Labels, cluster: cluster.Name, meter: meter, poller: poller, jmx: map[string]bool{}}
+	var err error
+	gauge := func(name, description string) metric.Int64ObservableGauge {
+		if err != nil {
+			return nil
+		}
+		var instrument metric.Int64ObservableGauge
+		instrument, err = meter.Int64ObservableGauge(name, metric.WithDescription(description))
+		return instrument
+	}
+	floatGauge := func(name, description string, options ...metric.Float64ObservableGaugeOption) metric.Float64ObservableGauge {
+		if err != nil {
+			return nil
+		}
+		var instrument metric.Float64ObservableGauge
+		instrument, err = meter.Float64ObservableGauge(name, append(options, metric.WithDescription(description))...)
+		return instrument
+	}
+	prefix := "kafka_connect_connector"
+
+	i.up = gauge("kafka_connect_up", "Whether the last poll of the host succeeded (1) or failed (0)")
+	i.connectorCount = gauge(prefix+"_total", "Total number of connectors")
+	i.filtered = gauge(prefix+"_filtered", "Number of connectors excluded by the filters of the cluster")
+	i.connectorStatus = gauge(prefix+"_status", "Status of the connector (e.g. `RUNNING`, `PAUSED`, `FAILED`)")
+	i.connectorInfo = gauge(prefix+"_info", "Type, class and plugin version of the connector")
+	i.running = gauge(prefix+"_running_total", "Total number of tasks in the `RUNNING` state")
+	i.failed = gauge(prefix+"_failed_total", "Total number of tasks in the `FAILED` state (e.g., due to exceptions reported in status)")
+	i.paused = gauge(prefix+"_paused_total", "Total number of tasks in the `PAUSED` state (e.g., administratively paused)")
+	i.unassigned = gauge(prefix+"_unassigned_total", "Total number of tasks in the `UNASSIGNED` state (e.g., not assigned to any worker)")
+	i.taskCount = gauge(prefix+"_task_total", "Total number of tasks for the connector")
+	i.taskState = gauge("kafka_connect_task_state", "State of the task, 1 for the current state and 0 for every other state")
+	i.taskFailed = gauge("kafka_connect_task_failed", "Failed task with the class of the root exception of its trace, always 1")
+	i.workerInfo = gauge("kafka_connect_worker_info", "Version and commit of the worker and the id of the kafka cluster it is connected to, always 1")
+	i.workerConnectors = gauge("kafka_connect_worker_connectors", "Number of connectors assigned to the worker by state")
+	i.workerTasks = gauge("kafka_connect_worker_tasks", "Number of tasks assigned to the worker by state")
+	i.jolokiaUp = gauge("kafka_connect_jolokia_up", "Whether the last read of the MBeans of the host with Jolokia succeeded (1) or failed (0)")
+	i.offsetPartitions = gauge(prefix+"_offset_partitions", "Number of partitions with offsets of the connector, including the partitions beyond the export limit")
+	i.stalled = gauge(prefix+"_stalled", "Whether a task of the connector is running while its offsets did not change for the stall threshold (1) or not (0)")
+	i.topicInfo = gauge(prefix+"_topic_info", "Topic used by the connector since its creation or the last reset of its active topics, always 1")
+	i.scrapeDuration = floatGauge("kafka_connect_scrape_duration_seconds", "Duration of the last poll of the host", metric.WithUnit("s"))
+	i.snapshotAge = floatGauge("kafka_connect_snapshot_age_seconds", "Seconds since the served snapshot was collected", metric.WithUnit("s"))
+	i.lastSuccess = floatGauge("kafka_connect_last_successful_poll_timestamp_seconds", "Unix time of the last successful poll of the host", metric.WithUnit("s"))
+	i.sinkOffset = floatGauge("kafka_connect_sink_partition_offset", "Offset of the topic partition committed by the sink connector")
+	i.sourceOffset = floatGauge("kafka_connect_source_partition_offset", "Numeric field of the offset of the source partition committed by the source connector, the partition as JSON")
+	i.sinceProgress = floatGauge(prefix+"_seconds_since_progress", "Seconds since any offset of the connector changed, as of the last poll", metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	if i.scrapeErrors, err = meter.Int64ObservableCounter("kafka_connect_scrape_errors_total", metric.WithDescription("Total number of failed polls of the host by kind (`dial`, `tls`, `connection`, `timeout`, `status`, `decode`)")); err != nil {
+		return nil, err
+	}
+	if i.rebalances, err = meter.Int64ObservableCounter("kafka_connect_rebalances_total", metric.WithDescription("Total number of polls in which a task was assigned to another worker than in the previous poll")); err != nil {
+		return nil, err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, observer metric.Observer) error {
+		snapshot := poller.Snapshot()
+		if snapshot == nil {
+			return nil
+		}
+		observer.ObserveFloat64(i.snapshotAge, time.Since(snapshot.Time).Seconds())
+		for _, host := range snapshot.Hosts {
+			if host.Cluster == cluster.Name {
+				i.observeHost(observer, host)
+			}
+		}
+		return nil
+	}, i.snapshotAge, i.lastSuccess, i.up, i.scrapeDuration, i.scrapeErrors, i.connectorCount, i.filtered, i.connectorStatus, i.connectorInfo,
+		i.running, i.failed, i.paused, i.unassigned, i.taskCount, i.taskState, i.taskFailed,
+		i.workerInfo, i.workerConnectors, i.workerTasks, i.rebalances,
+		i.jolokiaUp, i.sinkOffset, i.sourceOffset, i.offsetPartitions, i.sinceProgress, i.stalled, i.topicInfo)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot := poller.Snapshot(); snapshot != nil {
+		if err := i.registerJMX(snapshot); err != nil {
+			return nil, err
+		}
+	}
+	return i, nil
+}
+
+// Register a gauge for every MBean attribute of the hosts of the cluster in the snapshot that has none yet.
+// The names of the gauges are only known from the snapshots, and instruments cannot be created while observing.
+func (i *instruments) registerJMX(snapshot *poller.Snapshot) error {
+	for _, host := range snapshot.Hosts {
+		if host.Cluster != i.cluster {
+			continue
+		}
+		for _, jmx := range host.JMX {
+			name := jmx.Name()
+			if i.jmx[name] {
+				continue
+			}
+			_, err := i.meter.Float64ObservableGauge(name,
+				metric.WithDescription(fmt.Sprintf("Attribute %s of the kafka.connect MBeans of type %s, read with Jolokia", jmx.Attribute, jmx.Type)),
+				metric.WithFloat64Callback(func(_ context.Context, observer metric.Float64Observer) error {
+					i.observeJMX(observer, name)
+					return nil
+				}))
+			if err != nil {
+				return err
+			}
+			i.jmx[name] = true
+		}
+	}
+	return nil
+}
+
+// Observe the MBean attributes with the name of the gauge in the latest snapshot of the poller
+func (i *instruments) observeJMX(observer metric.Float64Observer, name string) {
+	snapshot := i.poller.Snapshot()
+	if snapshot == nil {
+		return
+	}
+	for _, host := range snapshot.Hosts {
+		if host.Cluster != i.cluster || host.Err != nil {
+			continue
+		}
+		hostAttributes := i.hostAttributes(host)
+		for _, jmx := range host.JMX {
+			if jmx.Name() == name {
+				observer.Observe(jmx.Value, metric.WithAttributes(slices.Concat(hostAttributes,
+					[]attribute.KeyValue{attribute.String("connector", jmx.Connector), attribute.String("task", jmx.Task)})...))
+			}
+		}
+	}
+}
+
+func (i *instruments) observeHost(observer metric.Observer, host *poller.HostSnapshot) {
+	hostAttributes := i.hostAttributes(host)
+	withHost := func(attributes ...attribute.KeyValue) metric.MeasurementOption {
+		return metric.WithAttributes(slices.Concat(hostAttributes, attributes)...)
+	}
+
+	observer.ObserveFloat64(i.scrapeDuration, host.Duration.Seconds(), withHost())
+	for kind, count := range host.Errors {
+		observer.ObserveInt64(i.scrapeErrors, int64(count), withHost(attribute.String("kind", kind)))
+	}
+	observer.ObserveInt64(i.rebalances, int64(host.Rebalances), withHost())
+	if !host.LastSuccess.IsZero() {
+		observer.ObserveFloat64(i.lastSuccess, float64(host.LastSuccess.UnixNano())/1e9, withHost())
+	}
+	if host.Err != nil {
+		observer.ObserveInt64(i.up, 0, withHost())
+		return
+	}
+	observer.ObserveInt64(i.up, 1, withHost())
+	observer.ObserveInt64(i.connectorCount, int64(len(host.Connectors)), withHost())
+	observer.ObserveInt64(i.filtered, int64(host.Filtered), withHost())
+	if host.Worker != nil {
+		observer.ObserveInt64(i.workerInfo, 1, withHost(
+			attribute.String("version", host.Worker.Version),
+			attribute.String("commit", host.Worker.Commit),
+			attribute.String("kafka_cluster_id", host.Worker.KafkaClusterID)))
+	}
+
+	if host.JMXErr != nil {
+		observer.ObserveInt64(i.jolokiaUp, 0, withHost())
+	} else if host.JMX != nil {
+		observer.ObserveInt64(i.jolokiaUp, 1, withHost())
+	}
+	for connector, offsets := range host.Offsets {
+		i.observeOffsets(observer, host, append(slices.Clip(hostAttributes), attribute.String("connector", connector)), offsets)
+	}
+	for connector, topics := range host.Topics {
+		for _, topic := range topics {
+			observer.ObserveInt64(i.topicInfo, 1, withHost(attribute.String("connector", connector), attribute.String("topic", topic)))
+		}
+	}
+
+	for worker, load := range host.Workers() {
+		workerAttribute := attribute.String("worker_id", worker)
+		for _, state := range states.Connectors {
+			observer.ObserveInt64(i.workerConnectors, int64(load.Connectors[state]), withHost(workerAttribute, attribute.String("state", state)))
+		}
+		for _, state := range states.Tasks {
+			observer.ObserveInt64(i.workerTasks, int64(load.Tasks[state]), withHost(workerAttribute, attribute.String("state", state)))
+		}
+	}
+
+	for name, connector := range host.Connectors {
+		connectorAttribute := attribute.String("connector", name)
+		if connector.Info != nil {
+			class := connector.Info.Config["connector.class"]
+			observer.ObserveInt64(i.connectorInfo, 1, withHost(connectorAttribute,
+				attribute.String("type", connector.Type()),
+				attribute.String("class", class),
+				attribute.String("version", collector.PluginVersion(host.Plugins, class))))
+		}
+		if connector.Status != nil {
+			i.observeConnector(observer, append(slices.Clip(hostAttributes), connectorAttribute), connector.Status)
+		}
+	}
+}
+
+// The host and its labels that are not static labels of the cluster, e.g. the namespace and pod of discovered hosts
+func (i *instruments) hostAttributes(host *poller.HostSnapshot) []attribute.KeyValue {
+	attributes := []attribute.KeyValue{attribute.String("host", host.Host)}
+	for name, value := range host.Labels {
+		if clusterValue, ok := i.clusterLabels[name]; !ok || clusterValue != value {
+			attributes = append(attributes, attribute.String(name, value))
+		}
+	}
+	return attributes
+}
+
+// Observe a connector with the attributes of its host and the connector
+func (i *instruments) observeConnector(observer metric.Observer, connectorAttributes []attribute.KeyValue, status *collector.ConnectorStatus) {
+	withConnector := func(attributes ...attribute.KeyValue) metric.MeasurementOption {
+		return metric.WithAttributes(slices.Concat(connectorAttributes, attributes)...)
+	}
+	observer.ObserveInt64(i.connectorStatus, 1, withConnector(attribute.String("status", status.Connector.State)))
+
+	var running, paused, failed, unassigned int64
+	for _, task := range status.Tasks {
+		taskAttributes := []attribute.KeyValue{attribute.String("task", strconv.Itoa(task.ID)), attribute.String("worker_id", task.WorkerID)}
+		for _, state := range states.Tasks {
+			var value int64
+			if task.State == state {
+				value = 1
+			}
+			observer.ObserveInt64(i.taskState, value, withConnector(append(taskAttributes, attribute.String("state", state))...))
+		}
+
+		switch task.State {
+		case "RUNNING":
+			running++
+		case "PAUSED":
+			paused++
+		case "FAILED":
+			failed++
+			observer.ObserveInt64(i.taskFailed, 1, withConnector(append(taskAttributes, attribute.String("exception", collector.RootException(task.Trace)))...))
+		default:
+			unassigned++
+		}
+	}
+
+	attributes := withConnector()
+	observer.ObserveInt64(i.running, running, attributes)
+	observer.ObserveInt64(i.paused, paused, attributes)
+	observer.ObserveInt64(i.failed, failed, attributes)
+	observer.ObserveInt64(i.unassigned, unassigned, attributes)
+	observer.ObserveInt64(i.taskCount, int64(len(status.Tasks)), attributes)
+}
+
+// Observe the offsets of a connector with the attributes of its host and the connector
+func (i *instruments) observeOffsets(observer metric.Observer, host *poller.HostSnapshot, connectorAttributes []attribute.KeyValue, offsets *poller.ConnectorOffsets) {
+	withConnector := func(attributes ...attribute.KeyValue) metric.MeasurementOption {
+		return metric.WithAttributes(slices.Concat(connectorAttributes, attributes)...)
+	}
+	observer.ObserveInt64(i.offsetPartitions, int64(offsets.Partitions), withConnector())
+	var stalled int64
+	if offsets.Stalled {
+		stalled = 1
+	}
+	observer.ObserveFloat64(i.sinceProgress, host.Time.Sub(offsets.LastProgress).Seconds(), withConnector())
+	observer.ObserveInt64(i.stalled, stalled, withConnector())
+
+	for _, offset := range offsets.Offsets {
+		if topic, partition, value, ok := offset.Sink(); ok {
+			observer.ObserveFloat64(i.sinkOffset, value, withConnector(attribute.String("topic", topic), attribute.String("partition", partition)))
+			continue
+		}
+
+		partition, err := json.Marshal(offset.Partition)
+		if err != nil {
+			continue
+		}
+		for key, value := range offset.Offset {
+			// only numeric fields can be exported, e.g. the position in a file but not the name of a binlog
+			if number, ok := value.(float64); ok {
+				observer.ObserveFloat64(i.sourceOffset, number, withConnector(attribute.String("partition", string(partition)), attribute.String("key", key)))
+			}
+		}
+	}
+}
@@ -1,13 +1,15 @@
 package exporter
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
-	"sync"
+	"strconv"
+	"time"
 
 	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
-	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
-	applicationError "github.com/Ecube-Labs/kafka-connect-exporter/pkg/application-error"
-	"github.com/Ecube-Labs/kafka-connect-exporter/pkg/logger"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/states"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -15,129 +17,262 @@ import (
 // A struct that implements the prometheus.Collector interface.
 // https://github.com/prometheus/client_golang/blob/7b39d0144166aa94cc8ce4125bcb3b0da89aad5e/prometheus/collector.go#L27
 type exporter struct {
-	collector           *collector.Collector
-	descUnassigned      *prometheus.Desc
-	descRunning         *prometheus.Desc
-	descFailed          *prometheus.Desc
-	descPaused          *prometheus.Desc
-	descTaskCount       *prometheus.Desc
-	descConnectorCount  *prometheus.Desc
-	descConnectorStatus *prometheus.Desc
+	poller *poller.Poller
 }
 
-func New(collector *collector.Collector) *exporter {
-	labels := []string{"connector", "host"}
-	prefix := "kafka_connect_connector"
+// The descriptions are built on every collection, because the static labels of the hosts are only known from the snapshot.
+type descs struct {
+	unassigned       *prometheus.Desc
+	running          *prometheus.Desc
+	failed           *prometheus.Desc
+	paused           *prometheus.Desc
+	taskCount        *prometheus.Desc
+	connectorCount   *prometheus.Desc
+	connectorStatus  *prometheus.Desc
+	snapshotAge      *prometheus.Desc
+	lastSuccess      *prometheus.Desc
+	up               *prometheus.Desc
+	scrapeDuration   *prometheus.Desc
+	scrapeErrors     *prometheus.Desc
+	taskState        *prometheus.Desc
+	connectorInfo    *prometheus.Desc
+	filtered         *prometheus.Desc
+	taskFailed       *prometheus.Desc
+	workerInfo       *prometheus.Desc
+	workerConnectors *prometheus.Desc
+	workerTasks      *prometheus.Desc
+	rebalances       *prometheus.Desc
+	jolokiaUp        *prometheus.Desc
+	sinkOffset       *prometheus.Desc
+	sourceOffset     *prometheus.Desc
+	offsetPartitions *prometheus.Desc
+	sinceProgress    *prometheus.Desc
+	stalled          *prometheus.Desc
+	topicInfo        *prometheus.Desc
+	// the descriptions of the MBean attributes by metric name, created on first use
+	jmx          map[string]*prometheus.Desc
+	staticLabels []string
+}
 
+func New(poller *poller.Poller) *exporter {
 	exporter := &exporter{
-		collector:           collector,
-		descRunning:         prometheus.NewDesc(prefix+"_running_total", "Total number of tasks in the `RUNNING` state", labels, nil),
-		descFailed:          prometheus.NewDesc(prefix+"_failed_total", "Total number of tasks in the `FAILED` state (e.g., due to exceptions reported in status)", labels, nil),
-		descPaused:          prometheus.NewDesc(prefix+"_paused_total", "Total number of tasks in the `PAUSED` state (e.g., administratively paused)", labels, nil),
-		descUnassigned:      prometheus.NewDesc(prefix+"_unassigned_total", "Total number of tasks in the `UNASSIGNED` state (e.g., not assigned to any worker)", labels, nil),
-		descTaskCount:       prometheus.NewDesc(prefix+"_task_total", "Total number of tasks for the connector", labels, nil),
-		descConnectorCount:  prometheus.NewDesc(prefix+"_total", "Total number of connectors", []string{"host"}, nil),
-		descConnectorStatus: prometheus.NewDesc(prefix+"_status", "Status of the connector (e.g. `RUNNING`, `PAUSED`, `FAILED`)", []string{"host", "connector", "status"}, nil),
+		poller: poller,
 	}
 	prometheus.MustRegister(exporter)
 
 	return exporter
 }
 
+// A registry with only the metrics of the given poller, e.g. for a single probe
+func NewRegistry(poller *poller.Poller) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&exporter{poller: poller})
+	return registry
+}
+
+func newDescs(staticLabels []string) *descs {
+	withStaticLabels := func(labels ...string) []string {
+		return append(labels, staticLabels...)
+	}
+	labels := withStaticLabels("connector", "host")
+	prefix := "kafka_connect_connector"
+
+	return &descs{
+		running:          prometheus.NewDesc(prefix+"_running_total", "Total number of tasks in the `RUNNING` state", labels, nil),
+		failed:           prometheus.NewDesc(prefix+"_failed_total", "Total number of tasks in the `FAILED` state (e.g., due to exceptions reported in status)", labels, nil),
+		paused:           prometheus.NewDesc(prefix+"_paused_total", "Total number of tasks in the `PAUSED` state (e.g., administratively paused)", labels, nil),
+		unassigned:       prometheus.NewDesc(prefix+"_unassigned_total", "Total number of tasks in the `UNASSIGNED` state (e.g., not assigned to any worker)", labels, nil),
+		taskCount:        prometheus.NewDesc(prefix+"_task_total", "Total number of tasks for the connector", labels, nil),
+		connectorCount:   prometheus.NewDesc(prefix+"_total", "Total number of connectors", withStaticLabels("host"), nil),
+		connectorStatus:  prometheus.NewDesc(prefix+"_status", "Status of the connector (e.g. `RUNNING`, `PAUSED`, `FAILED`)", withStaticLabels("host", "connector", "status"), nil),
+		snapshotAge:      prometheus.NewDesc("kafka_connect_snapshot_age_seconds", "Seconds since the served snapshot was collected", nil, nil),
+		lastSuccess:      prometheus.NewDesc("kafka_connect_last_successful_poll_timestamp_seconds", "Unix time of the last successful poll of the host", withStaticLabels("host"), nil),
+		up:               prometheus.NewDesc("kafka_connect_up", "Whether the last poll of the host succeeded (1) or failed (0)", withStaticLabels("host"), nil),
+		scrapeDuration:   prometheus.NewDesc("kafka_connect_scrape_duration_seconds", "Duration of the last poll of the host", withStaticLabels("host"), nil),
+		scrapeErrors:     prometheus.NewDesc("kafka_connect_scrape_errors_total", "Total number of failed polls of the host by kind (`dial`, `tls`, `connection`, `timeout`, `status`, `decode`)", withStaticLabels("host", "kind"), nil),
+		taskState:        prometheus.NewDesc("kafka_connect_task_state", "State of the task, 1 for the current state and 0 for every other state", withStaticLabels("host", "connector", "task", "worker_id", "state"), nil),
+		filtered:         prometheus.NewDesc(prefix+"_filtered", "Number of connectors excluded by the filters of the cluster", withStaticLabels("host"), nil),
+		taskFailed:       prometheus.NewDesc("kafka_connect_task_failed", "Failed task with the class of the root exception of its trace, always 1", withStaticLabels("host", "connector", "task", "worker_id", "exception"), nil),
+		connectorInfo:    prometheus.NewDesc(prefix+"_info", "Type, class and plugin version of the connector", withStaticLabels("host", "connector", "type", "class", "version"), nil),
+		workerInfo:       prometheus.NewDesc("kafka_connect_worker_info", "Version and commit of the worker and the id of the kafka cluster it is connected to, always 1", withStaticLabels("host", "version", "commit", "kafka_cluster_id"), nil),
+		workerConnectors: prometheus.NewDesc("kafka_connect_worker_connectors", "Number of connectors assigned to the worker by state", withStaticLabels("host", "worker_id", "state"), nil),
+		workerTasks:      prometheus.NewDesc("kafka_connect_worker_tasks", "Number of tasks assigned to the worker by state", withStaticLabels("host", "worker_id", "state"), nil),
+		jolokiaUp:        prometheus.NewDesc("kafka_connect_jolokia_up", "Whether the last read of the MBeans of the host with Jolokia succeeded (1) or failed (0)", withStaticLabels("host"), nil),
+		jmx:              map[string]*prometheus.Desc{},
+		sinkOffset:       prometheus.NewDesc("kafka_connect_sink_partition_offset", "Offset of the topic partition committed by the sink connector", withStaticLabels("host", "connector", "topic", "partition"), nil),
+		sourceOffset:     prometheus.NewDesc("kafka_connect_source_partition_offset", "Numeric field of the offset of the source partition committed by the source connector, the partition as JSON", withStaticLabels("host", "connector", "partition", "key"), nil),
+		sinceProgress:    prometheus.NewDesc(prefix+"_seconds_since_progress", "Seconds since any offset of the connector changed, as of the last poll", withStaticLabels("host", "connector"), nil),
+		stalled:          prometheus.NewDesc(prefix+"_stalled", "Whether a task of the connector is running while its offsets did not change for the stall threshold (1) or not (0)", withStaticLabels("host", "connector"), nil),
+		topicInfo:        prometheus.NewDesc(prefix+"_topic_info", "Topic used by the connector since its creation or the last reset of its active topics, always 1", withStaticLabels("host", "connector", "topic"), nil),
+		offsetPartitions: prometheus.NewDesc(prefix+"_offset_partitions", "Number of partitions with offsets of the connector, including the partitions beyond the export limit", withStaticLabels("host", "connector"), nil),
+		staticLabels:     staticLabels,
+		rebalances:       prometheus.NewDesc("kafka_connect_rebalances_total", "Total number of polls in which a task was assigned to another worker than in the previous poll", withStaticLabels("host"), nil),
+	}
+}
+
+// The description of a numeric MBean attribute, created on first use
+func (d *descs) jmxDesc(metric collector.JMXMetric) *prometheus.Desc {
+	name := metric.Name()
+	desc, ok := d.jmx[name]
+	if !ok {
+		help := fmt.Sprintf("Attribute %s of the kafka.connect MBeans of type %s, read with Jolokia", metric.Attribute, metric.Type)
+		desc = prometheus.NewDesc(name, help, append([]string{"host", "connector", "task"}, d.staticLabels...), nil)
+		d.jmx[name] = desc
+	}
+	return desc
+}
+
 // Describe is a no-op, because the Collector dynamically allocates metrics.
 // https://github.com/prometheus/client_golang/blob/v1.9.0/prometheus/Collector.go#L28-L40
 func (e *exporter) Describe(ch chan<- *prometheus.Desc) {}
 
+// Collect only serializes the latest snapshot of the poller, it never calls the kafka connect REST API.
 func (e *exporter) Collect(ch chan<- prometheus.Metric) {
-	var wg sync.WaitGroup
+	snapshot := e.poller.Snapshot()
+	if snapshot == nil {
+		return
+	}
+	labelNames := snapshot.LabelNames()
+	descs := newDescs(labelNames)
+
+	ch <- prometheus.MustNewConstMetric(descs.snapshotAge, prometheus.GaugeValue, time.Since(snapshot.Time).Seconds())
 
-	// collect metrics for each kafka connect host
-	for _, host := range config.KafkaConnectHosts {
-		wg.Add(1)
+	for _, host := range snapshot.Hosts {
+		staticLabelValues := host.LabelValues(labelNames)
+		var metric metricFunc = func(desc *prometheus.Desc, valueType prometheus.ValueType, value float64, labelValues ...string) {
+			ch <- prometheus.MustNewConstMetric(desc, valueType, value, append(labelValues, staticLabelValues...)...)
+		}
 
-		go func(h string) {
-			defer wg.Done()
+		metric(descs.scrapeDuration, prometheus.GaugeValue, host.Duration.Seconds(), host.Host)
+		for kind, count := range host.Errors {
+			metric(descs.scrapeErrors, prometheus.CounterValue, float64(count), host.Host, kind)
+		}
+		metric(descs.rebalances, prometheus.CounterValue, float64(host.Rebalances), host.Host)
+		if !host.LastSuccess.IsZero() {
+			metric(descs.lastSuccess, prometheus.GaugeValue, float64(host.LastSuccess.UnixNano())/1e9, host.Host)
+		}
+		if host.Err != nil {
+			metric(descs.up, prometheus.GaugeValue, 0, host.Host)
+			continue
+		}
+		metric(descs.up, prometheus.GaugeValue, 1, host.Host)
+		metric(descs.connectorCount, prometheus.GaugeValue, float64(len(host.Connectors)), host.Host)
+		metric(descs.filtered, prometheus.GaugeValue, float64(host.Filtered), host.Host)
+		if host.Worker != nil {
+			metric(descs.workerInfo, prometheus.GaugeValue, 1, host.Host, host.Worker.Version, host.Worker.Commit, host.Worker.KafkaClusterID)
+		}
 
-			connectors, err := e.collector.GetConnectors(h)
-			if err != nil {
-				logger.Log("error", applicationError.UnWrap(err).Stack)
-				return
+		if host.JMXErr != nil {
+			metric(descs.jolokiaUp, prometheus.GaugeValue, 0, host.Host)
+		} else if host.JMX != nil {
+			metric(descs.jolokiaUp, prometheus.GaugeValue, 1, host.Host)
+		}
+		for _, jmx := range host.JMX {
+			metric(descs.jmxDesc(jmx), prometheus.GaugeValue, jmx.Value, host.Host, jmx.Connector, jmx.Task)
+		}
+
+		for connector, offsets := range host.Offsets {
+			collectOffsets(descs, metric, host, connector, offsets)
+		}
+		for connector, topics := range host.Topics {
+			for _, topic := range topics {
+				metric(descs.topicInfo, prometheus.GaugeValue, 1, host.Host, connector, topic)
+			}
+		}
+
+		for worker, load := range host.Workers() {
+			for _, state := range states.Connectors {
+				metric(descs.workerConnectors, prometheus.GaugeValue, float64(load.Connectors[state]), host.Host, worker, state)
 			}
-			ch <- prometheus.MustNewConstMetric(e.descConnectorCount, prometheus.GaugeValue, float64(len(connectors)), h)
-
-			for _, connector := range connectors {
-				status, err := e.collector.GetConnectorStatus(h, connector)
-				if err != nil {
-					logger.Log("error", applicationError.UnWrap(err).Stack)
-					continue
-				}
-
-				ch <- prometheus.MustNewConstMetric(e.descConnectorStatus, prometheus.GaugeValue, 1, h, connector, status.Connector.State)
-
-				var unAssignedTaskCount int
-				var runningTaskCount int
-				var pausedTaskCount int
-				var failedTaskCount int
-
-				for _, task := range status.Tasks {
-					switch task.State {
-					case "RUNNING":
-						runningTaskCount++
-					case "PAUSED":
-						pausedTaskCount++
-					case "FAILED":
-						failedTaskCount++
-					default:
-						unAssignedTaskCount++
-					}
-				}
-				metric := ConnectorStatusMetric{
-					UnAssignedTaskCount: unAssignedTaskCount,
-					RunningTaskCount:    runningTaskCount,
-					PausedTaskCount:     pausedTaskCount,
-					FailedTaskCount:     failedTaskCount,
-					TotalTaskCount:      len(status.Tasks),
-				}
-
-				ch <- prometheus.MustNewConstMetric(
-					e.descUnassigned,
-					prometheus.GaugeValue,
-					float64(metric.UnAssignedTaskCount),
-					connector, h,
-				)
-
-				ch <- prometheus.MustNewConstMetric(
-					e.descRunning,
-					prometheus.GaugeValue,
-					float64(metric.RunningTaskCount),
-					connector, h,
-				)
-
-				ch <- prometheus.MustNewConstMetric(
-					e.descFailed,
-					prometheus.GaugeValue,
-					float64(metric.FailedTaskCount),
-					connector, h,
-				)
-
-				ch <- prometheus.MustNewConstMetric(
-					e.descPaused,
-					prometheus.GaugeValue,
-					float64(metric.PausedTaskCount),
-					connector, h,
-				)
-
-				ch <- prometheus.MustNewConstMetric(
-					e.descTaskCount,
-					prometheus.GaugeValue,
-					float64(metric.TotalTaskCount),
-					connector, h,
-				)
+			for _, state := range states.Tasks {
+				metric(descs.workerTasks, prometheus.GaugeValue, float64(load.Tasks[state]), host.Host, worker, state)
 			}
-		}(host)
+		}
+
+		for connector, expanded := range host.Connectors {
+			if expanded.Info != nil {
+				class := expanded.Info.Config["connector.class"]
+				metric(descs.connectorInfo, prometheus.GaugeValue, 1, host.Host, connector, expanded.Type(), class, collector.PluginVersion(host.Plugins, class))
+			}
+			if expanded.Status == nil {
+				continue
+			}
+			collectConnector(descs, metric, host.Host, connector, expanded.Status)
+		}
+	}
+}
+
+func collectConnector(descs *descs, metric metricFunc, host, connector string, status *collector.ConnectorStatus) {
+	metric(descs.connectorStatus, prometheus.GaugeValue, 1, host, connector, status.Connector.State)
+
+	var unAssignedTaskCount int
+	var runningTaskCount int
+	var pausedTaskCount int
+	var failedTaskCount int
+
+	for _, task := range status.Tasks {
+		for _, state := range states.Tasks {
+			var value float64
+			if task.State == state {
+				value = 1
+			}
+			metric(descs.taskState, prometheus.GaugeValue, value, host, connector, strconv.Itoa(task.ID), task.WorkerID, state)
+		}
+
+		switch task.State {
+		case "RUNNING":
+			runningTaskCount++
+		case "PAUSED":
+			pausedTaskCount++
+		case "FAILED":
+			failedTaskCount++
+			metric(descs.taskFailed, prometheus.GaugeValue, 1, host, connector, strconv.Itoa(task.ID), task.WorkerID, collector.RootException(task.Trace))
+		default:
+			unAssignedTaskCount++
+		}
 	}
+	statusMetric := ConnectorStatusMetric{
+		UnAssignedTaskCount: unAssignedTaskCount,
+		RunningTaskCount:    runningTaskCount,
+		PausedTaskCount:     pausedTaskCount,
+		FailedTaskCount:     failedTaskCount,
+		TotalTaskCount:      len(status.Tasks),
+	}
+
+	metric(descs.unassigned, prometheus.GaugeValue, float64(statusMetric.UnAssignedTaskCount), connector, host)
+	metric(descs.running, prometheus.GaugeValue, float64(statusMetric.RunningTaskCount), connector, host)
+	metric(descs.failed, prometheus.GaugeValue, float64(statusMetric.FailedTaskCount), connector, host)
+	metric(descs.paused, prometheus.GaugeValue, float64(statusMetric.PausedTaskCount), connector, host)
+	metric(descs.taskCount, prometheus.GaugeValue, float64(statusMetric.TotalTaskCount), connector, host)
+}
 
-	wg.Wait()
+func collectOffsets(descs *descs, metric metricFunc, host *poller.HostSnapshot, connector string, offsets *poller.ConnectorOffsets) {
+	metric(descs.offsetPartitions, prometheus.GaugeValue, float64(offsets.Partitions), host.Host, connector)
+
+	stalled := 0.0
+	if offsets.Stalled {
+		stalled = 1
+	}
+	metric(descs.sinceProgress, prometheus.GaugeValue, host.Time.Sub(offsets.LastProgress).Seconds(), host.Host, connector)
+	metric(descs.stalled, prometheus.GaugeValue, stalled, host.Host, connector)
+
+	for _, offset := range offsets.Offsets {
+		if topic, partition, value, ok := offset.Sink(); ok {
+			metric(descs.sinkOffset, prometheus.GaugeValue, value, host.Host, connector, topic, partition)
+			continue
+		}
+
+		partition, err := json.Marshal(offset.Partition)
+		if err != nil {
+			continue
+		}
+		for key, value := range offset.Offset {
+			// only numeric fields can be exported, e.g. the position in a file but not the name of a binlog
+			if number, ok := value.(float64); ok {
+				metric(descs.sourceOffset, prometheus.GaugeValue, number, host.Host, connector, string(partition), key)
+			}
+		}
+	}
 }
 
 func (e *exporter) Handler() http.Handler {
@@ -2,25 +2,32 @@ package exporter
 
 import (
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
 	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/states"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNew(t *testing.T) {
 	t.Run("Should return a new exporter", func(t *testing.T) {
-		collector := collector.New(&http.Client{Transport: &mockRoundTripper{}})
-		exporter := New(collector)
+		exporter := New(poller.New(nil, time.Minute))
 		assert.NotNil(t, exporter)
 	})
 	t.Run("Should return a exporter implementing the prometheus.Collector interface", func(t *testing.T) {
-		collector := collector.New(&http.Client{Transport: &mockRoundTripper{}})
-		exporter := New(collector)
+		exporter := New(poller.New(nil, time.Minute))
 		assert.Implements(t, (*prometheus.Collector)(nil), exporter)
 	})
 }
@@ -33,10 +40,32 @@ func (m *mockRoundTripper) RoundTrip(req *http.Request) (*http.Response, error)
 	return m.roundTripFunc(req)
 }
 
+func newTargets(roundTripper http.RoundTripper, hosts []string) []poller.Target {
+	collector := collector.New(&http.Client{Transport: roundTripper})
+	targets := make([]poller.Target, len(hosts))
+	for i, host := range hosts {
+		targets[i] = poller.Target{Cluster: "default", Host: host, Collector: collector}
+	}
+	return targets
+}
+
+func collect(exporter *exporter) []prometheus.Metric {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		exporter.Collect(ch)
+		close(ch)
+	}()
+
+	var collectedMetrics []prometheus.Metric
+	for metric := range ch {
+		collectedMetrics = append(collectedMetrics, metric)
+	}
+	return collectedMetrics
+}
+
 func TestCollect(t *testing.T) {
 	t.Run("Should collect metrics successfully", func(t *testing.T) {
 		mockHosts := []string{"http://test-host1", "http://test-host2"}
-		config.KafkaConnectHosts = mockHosts
 
 		mockConnectors := []string{"connector1", "connector2"}
 		roundTripper := &mockRoundTripper{
@@ -64,32 +93,475 @@ func TestCollect(t *testing.T) {
 			},
 		}
 
-		exporter := New(collector.New(&http.Client{Transport: roundTripper}))
+		poller := poller.New(newTargets(roundTripper, mockHosts), time.Minute)
+		poller.Poll()
+		exporter := New(poller)
 
-		// connectorCount metrics (1 per host)
-		hostMetricTotal := len(mockHosts)
+		// snapshotAge metric
+		snapshotMetricTotal := 1
+		// connectorCount, filtered, lastSuccess, up, scrapeDuration and rebalances metrics (6 per host) + scrapeErrors metrics (1 per host per failure kind)
+		hostMetricTotal := len(mockHosts) * (6 + len(collector.FailureKinds))
 		// connectorStatus metrics (1 per host per connector) + taskCount/taskStatus metrics (5 per host per connector)
 		connectorMetricTotal := len(mockHosts) * len(mockConnectors) * 6
+		// taskState metrics (5 per host per task) + taskFailed metrics (1 per host per failed task)
+		taskMetricTotal := len(mockHosts)*3*len(states.Tasks) + len(mockHosts)
+
+		assert.Equal(t, snapshotMetricTotal+hostMetricTotal+connectorMetricTotal+taskMetricTotal, len(collect(exporter)))
+	})
+
+	t.Run("Should collect the same metrics from the expanded connectors endpoint", func(t *testing.T) {
+		mockHosts := []string{"http://test-host1", "http://test-host2"}
+
+		requestCount := 0
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+
+				if req.URL.Path == "/connectors" {
+					requestCount++
+					response.Write([]byte(`{
+						"connector1": {"status": {"name": "connector1", "tasks": [{"state": "RUNNING"}, {"state": "FAILED"}]}},
+						"connector2": {"status": {"name": "connector2", "tasks": [{"state": "PAUSED"}]}}
+					}`))
+				} else {
+					response.WriteHeader(http.StatusNotFound)
+				}
+
+				return response.Result(), nil
+			},
+		}
+
+		poller := poller.New(newTargets(roundTripper, mockHosts), time.Minute)
+		poller.Poll()
+		exporter := New(poller)
+
+		// snapshotAge metric + host metrics + connectorStatus/taskCount/taskStatus metrics (6 per host per connector) + taskState metrics (5 per host per task) + taskFailed metrics (1 per host per failed task)
+		metricTotal := 1 + len(mockHosts)*(6+len(collector.FailureKinds)) + len(mockHosts)*2*6 + len(mockHosts)*3*len(states.Tasks) + len(mockHosts)
+
+		assert.Equal(t, metricTotal, len(collect(exporter)))
+		assert.Equal(t, len(mockHosts), requestCount)
+	})
+
+	t.Run("Should not call the API while collecting", func(t *testing.T) {
+		requestCount := 0
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				if req.URL.Path == "/connectors" {
+					requestCount++
+				}
+				response.Write([]byte(`{}`))
+				return response.Result(), nil
+			},
+		}
+
+		poller := poller.New(newTargets(roundTripper, []string{"http://test-host1"}), time.Minute)
+		exporter := New(poller)
+
+		assert.Empty(t, collect(exporter))
+
+		poller.Poll()
+		collect(exporter)
+		collect(exporter)
+
+		assert.Equal(t, 1, requestCount)
+	})
+
+	t.Run("Should export the state of every task with its worker", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				response.Write([]byte(`{
+					"connector1": {"status": {"name": "connector1", "tasks": [
+						{"id": 0, "state": "RUNNING", "worker_id": "worker1:8083"},
+						{"id": 1, "state": "FAILED", "worker_id": "worker2:8083", "trace": "org.apache.kafka.connect.errors.ConnectException"}
+					]}}
+				}`))
+				return response.Result(), nil
+			},
+		}
+
+		poller := poller.New(newTargets(roundTripper, []string{"http://test-host1"}), time.Minute)
+		poller.Poll()
+		exporter := New(poller)
+
+		err := testutil.CollectAndCompare(exporter, strings.NewReader(`
+# HELP kafka_connect_task_state State of the task, 1 for the current state and 0 for every other state
+# TYPE kafka_connect_task_state gauge
+kafka_connect_task_state{connector="connector1",host="http://test-host1",state="FAILED",task="0",worker_id="worker1:8083"} 0
+kafka_connect_task_state{connector="connector1",host="http://test-host1",state="PAUSED",task="0",worker_id="worker1:8083"} 0
+kafka_connect_task_state{connector="connector1",host="http://test-host1",state="RESTARTING",task="0",worker_id="worker1:8083"} 0
+kafka_connect_task_state{connector="connector1",host="http://test-host1",state="RUNNING",task="0",worker_id="worker1:8083"} 1
+kafka_connect_task_state{connector="connector1",host="http://test-host1",state="UNASSIGNED",task="0",worker_id="worker1:8083"} 0
+kafka_connect_task_state{connector="connector1",host="http://test-host1",state="FAILED",task="1",worker_id="worker2:8083"} 1
+kafka_connect_task_state{connector="connector1",host="http://test-host1",state="PAUSED",task="1",worker_id="worker2:8083"} 0
+kafka_connect_task_state{connector="connector1",host="http://test-host1",state="RESTARTING",task="1",worker_id="worker2:8083"} 0
+kafka_connect_task_state{connector="connector1",host="http://test-host1",state="RUNNING",task="1",worker_id="worker2:8083"} 0
+kafka_connect_task_state{connector="connector1",host="http://test-host1",state="UNASSIGNED",task="1",worker_id="worker2:8083"} 0
+# HELP kafka_connect_task_failed Failed task with the class of the root exception of its trace, always 1
+# TYPE kafka_connect_task_failed gauge
+kafka_connect_task_failed{connector="connector1",exception="org.apache.kafka.connect.errors.ConnectException",host="http://test-host1",task="1",worker_id="worker2:8083"} 1
+`), "kafka_connect_task_state", "kafka_connect_task_failed")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Should export the connectors and tasks of every worker and count rebalances", func(t *testing.T) {
+		worker := "worker1:8083"
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				response.Write([]byte(`{
+					"connector1": {"status": {"name": "connector1", "connector": {"state": "RUNNING", "worker_id": "worker1:8083"}, "tasks": [
+						{"id": 0, "state": "RUNNING", "worker_id": "worker1:8083"},
+						{"id": 1, "state": "FAILED", "worker_id": "` + worker + `"}
+					]}}
+				}`))
+				return response.Result(), nil
+			},
+		}
+
+		poller := poller.New(newTargets(roundTripper, []string{"http://test-host1"}), time.Minute)
+		poller.Poll()
+		worker = "worker2:8083"
+		poller.Poll()
+		exporter := New(poller)
+
+		err := testutil.CollectAndCompare(exporter, strings.NewReader(`
+# HELP kafka_connect_rebalances_total Total number of polls in which a task was assigned to another worker than in the previous poll
+# TYPE kafka_connect_rebalances_total counter
+kafka_connect_rebalances_total{host="http://test-host1"} 1
+# HELP kafka_connect_worker_connectors Number of connectors assigned to the worker by state
+# TYPE kafka_connect_worker_connectors gauge
+kafka_connect_worker_connectors{host="http://test-host1",state="FAILED",worker_id="worker1:8083"} 0
+kafka_connect_worker_connectors{host="http://test-host1",state="PAUSED",worker_id="worker1:8083"} 0
+kafka_connect_worker_connectors{host="http://test-host1",state="RESTARTING",worker_id="worker1:8083"} 0
+kafka_connect_worker_connectors{host="http://test-host1",state="RUNNING",worker_id="worker1:8083"} 1
+kafka_connect_worker_connectors{host="http://test-host1",state="STOPPED",worker_id="worker1:8083"} 0
+kafka_connect_worker_connectors{host="http://test-host1",state="UNASSIGNED",worker_id="worker1:8083"} 0
+kafka_connect_worker_connectors{host="http://test-host1",state="FAILED",worker_id="worker2:8083"} 0
+kafka_connect_worker_connectors{host="http://test-host1",state="PAUSED",worker_id="worker2:8083"} 0
+kafka_connect_worker_connectors{host="http://test-host1",state="RESTARTING",worker_id="worker2:8083"} 0
+kafka_connect_worker_connectors{host="http://test-host1",state="RUNNING",worker_id="worker2:8083"} 0
+kafka_connect_worker_connectors{host="http://test-host1",state="STOPPED",worker_id="worker2:8083"} 0
+kafka_connect_worker_connectors{host="http://test-host1",state="UNASSIGNED",worker_id="worker2:8083"} 0
+# HELP kafka_connect_worker_tasks Number of tasks assigned to the worker by state
+# TYPE kafka_connect_worker_tasks gauge
+kafka_connect_worker_tasks{host="http://test-host1",state="FAILED",worker_id="worker1:8083"} 0
+kafka_connect_worker_tasks{host="http://test-host1",state="PAUSED",worker_id="worker1:8083"} 0
+kafka_connect_worker_tasks{host="http://test-host1",state="RESTARTING",worker_id="worker1:8083"} 0
+kafka_connect_worker_tasks{host="http://test-host1",state="RUNNING",worker_id="worker1:8083"} 1
+kafka_connect_worker_tasks{host="http://test-host1",state="UNASSIGNED",worker_id="worker1:8083"} 0
+kafka_connect_worker_tasks{host="http://test-host1",state="FAILED",worker_id="worker2:8083"} 1
+kafka_connect_worker_tasks{host="http://test-host1",state="PAUSED",worker_id="worker2:8083"} 0
+kafka_connect_worker_tasks{host="http://test-host1",state="RESTARTING",worker_id="worker2:8083"} 0
+kafka_connect_worker_tasks{host="http://test-host1",state="RUNNING",worker_id="worker2:8083"} 0
+kafka_connect_worker_tasks{host="http://test-host1",state="UNASSIGNED",worker_id="worker2:8083"} 0
+`), "kafka_connect_rebalances_total", "kafka_connect_worker_connectors", "kafka_connect_worker_tasks")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Should export the type, class and plugin version of connectors", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				switch req.URL.Path {
+				case "/connectors":
+					response.Write([]byte(`{
+						"sink1": {
+							"status": {"name": "sink1", "tasks": [], "type": "sink"},
+							"info": {"name": "sink1", "config": {"connector.class": "io.confluent.connect.s3.S3SinkConnector"}, "type": "sink"}
+						},
+						"source1": {
+							"status": {"name": "source1", "tasks": [], "type": "source"},
+							"info": {"name": "source1", "config": {"connector.class": "FileStreamSource"}, "type": "source"}
+						}
+					}`))
+				case "/connector-plugins":
+					response.Write([]byte(`[
+						{"class": "io.confluent.connect.s3.S3SinkConnector", "type": "sink", "version": "10.5.0"},
+						{"class": "org.apache.kafka.connect.file.FileStreamSourceConnector", "type": "source", "version": "3.7.0"}
+					]`))
+				}
+				return response.Result(), nil
+			},
+		}
+
+		poller := poller.New(newTargets(roundTripper, []string{"http://test-host1"}), time.Minute)
+		poller.Poll()
+		exporter := New(poller)
+
+		err := testutil.CollectAndCompare(exporter, strings.NewReader(`
+# HELP kafka_connect_connector_info Type, class and plugin version of the connector
+# TYPE kafka_connect_connector_info gauge
+kafka_connect_connector_info{class="io.confluent.connect.s3.S3SinkConnector",connector="sink1",host="http://test-host1",type="sink",version="10.5.0"} 1
+kafka_connect_connector_info{class="FileStreamSource",connector="source1",host="http://test-host1",type="source",version="3.7.0"} 1
+`), "kafka_connect_connector_info")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Should export the version and kafka cluster of every worker", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				switch {
+				case req.URL.Path == "/connectors":
+					response.Write([]byte(`{}`))
+				case req.URL.Path == "/" && req.URL.Host == "test-host1":
+					response.Write([]byte(`{"version": "3.7.0", "commit": "2ae524ed625438c5", "kafka_cluster_id": "I4ZmrWqfT2e-upky_4fdPA"}`))
+				case req.URL.Path == "/" && req.URL.Host == "test-host2":
+					response.Write([]byte(`{"version": "3.6.1", "commit": "5e3c2b738d253ff5", "kafka_cluster_id": "I4ZmrWqfT2e-upky_4fdPA"}`))
+				default:
+					response.WriteHeader(http.StatusNotFound)
+				}
+				return response.Result(), nil
+			},
+		}
+
+		poller := poller.New(newTargets(roundTripper, []string{"http://test-host1", "http://test-host2", "http://test-host3"}), time.Minute)
+		poller.Poll()
+		exporter := New(poller)
+
+		err := testutil.CollectAndCompare(exporter, strings.NewReader(`
+# HELP kafka_connect_worker_info Version and commit of the worker and the id of the kafka cluster it is connected to, always 1
+# TYPE kafka_connect_worker_info gauge
+kafka_connect_worker_info{commit="2ae524ed625438c5",host="http://test-host1",kafka_cluster_id="I4ZmrWqfT2e-upky_4fdPA",version="3.7.0"} 1
+kafka_connect_worker_info{commit="5e3c2b738d253ff5",host="http://test-host2",kafka_cluster_id="I4ZmrWqfT2e-upky_4fdPA",version="3.6.1"} 1
+`), "kafka_connect_worker_info")
+		assert.NoError(t, err)
+	})
 
-		ch := make(chan prometheus.Metric, hostMetricTotal+connectorMetricTotal)
-		go func() {
-			exporter.Collect(ch)
-			close(ch)
-		}()
-
-		var collectedMetrics []prometheus.Metric
-		count := 0
-		for metric := range ch {
-			count++
-			collectedMetrics = append(collectedMetrics, metric)
+	t.Run("Should not query nor export connectors excluded by the filter", func(t *testing.T) {
+		var requestedPaths []string
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				requestedPaths = append(requestedPaths, req.URL.Path)
+				response := httptest.NewRecorder()
+				switch req.URL.Path {
+				case "/connectors":
+					response.Write([]byte(`["orders-sink", "test-1", "test-2"]`))
+				case "/connectors/orders-sink/status":
+					response.Write([]byte(`{"name": "orders-sink", "connector": {"state": "RUNNING"}, "tasks": []}`))
+				default:
+					response.WriteHeader(http.StatusNotFound)
+				}
+				return response.Result(), nil
+			},
 		}
 
-		assert.Equal(t, hostMetricTotal+connectorMetricTotal, len(collectedMetrics))
+		filter, err := collector.NewFilter(nil, []string{"test-.*"})
+		assert.Nil(t, err)
+		targets := newTargets(roundTripper, []string{"http://test-host1"})
+		targets[0].Filter = filter
+
+		poller := poller.New(targets, time.Minute)
+		poller.Poll()
+		exporter := New(poller)
+
+		assert.NotContains(t, requestedPaths, "/connectors/test-1/status")
+		assert.NotContains(t, requestedPaths, "/connectors/test-2/status")
+
+		err = testutil.CollectAndCompare(exporter, strings.NewReader(`
+# HELP kafka_connect_connector_filtered Number of connectors excluded by the filters of the cluster
+# TYPE kafka_connect_connector_filtered gauge
+kafka_connect_connector_filtered{host="http://test-host1"} 2
+# HELP kafka_connect_connector_status Status of the connector (e.g. `+"`RUNNING`, `PAUSED`, `FAILED`"+`)
+# TYPE kafka_connect_connector_status gauge
+kafka_connect_connector_status{connector="orders-sink",host="http://test-host1",status="RUNNING"} 1
+# HELP kafka_connect_connector_total Total number of connectors
+# TYPE kafka_connect_connector_total gauge
+kafka_connect_connector_total{host="http://test-host1"} 1
+`), "kafka_connect_connector_filtered", "kafka_connect_connector_status", "kafka_connect_connector_total")
+		assert.NoError(t, err)
 	})
 
-	t.Run("Should log errors when API fails", func(t *testing.T) {
+	t.Run("Should export the MBean attributes read with Jolokia with the labels of the state metrics", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/connectors":
+				w.Write([]byte(`{
+					"my-source": {"status": {"name": "my-source", "connector": {"state": "RUNNING"}, "tasks": [{"id": 0, "state": "RUNNING"}]}},
+					"test-1": {"status": {"name": "test-1", "connector": {"state": "RUNNING"}, "tasks": [{"id": 0, "state": "RUNNING"}]}}
+				}`))
+			case "/jolokia":
+				w.Write([]byte(`[
+					{"status": 200, "value": {"kafka.connect:type=connect-worker-metrics": {"connector-count": 2}}},
+					{"status": 200, "value": {
+						"kafka.connect:connector=my-source,task=0,type=source-task-metrics": {"source-record-poll-rate": 12.5},
+						"kafka.connect:connector=test-1,task=0,type=source-task-metrics": {"source-record-poll-rate": 1}
+					}}
+				]`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		port, err := strconv.Atoi(server.URL[strings.LastIndex(server.URL, ":")+1:])
+		assert.Nil(t, err)
+		filter, err := collector.NewFilter(nil, []string{"test-.*"})
+		assert.Nil(t, err)
+		targets := newTargets(http.DefaultTransport, []string{server.URL})
+		targets[0].Filter = filter
+		targets[0].JolokiaCollector = targets[0].Collector
+		targets[0].Jolokia = &config.Jolokia{Port: port, Path: "/jolokia", MBeans: []string{"kafka.connect:type=connect-worker-metrics,*", "kafka.connect:type=source-task-metrics,*"}}
+
+		poller := poller.New(targets, time.Minute)
+		poller.Poll()
+		exporter := New(poller)
+
+		err = testutil.CollectAndCompare(exporter, strings.NewReader(`
+# HELP kafka_connect_connect_worker_metrics_connector_count Attribute connector-count of the kafka.connect MBeans of type connect-worker-metrics, read with Jolokia
+# TYPE kafka_connect_connect_worker_metrics_connector_count gauge
+kafka_connect_connect_worker_metrics_connector_count{connector="",host="`+server.URL+`",task=""} 2
+# HELP kafka_connect_jolokia_up Whether the last read of the MBeans of the host with Jolokia succeeded (1) or failed (0)
+# TYPE kafka_connect_jolokia_up gauge
+kafka_connect_jolokia_up{host="`+server.URL+`"} 1
+# HELP kafka_connect_source_task_metrics_source_record_poll_rate Attribute source-record-poll-rate of the kafka.connect MBeans of type source-task-metrics, read with Jolokia
+# TYPE kafka_connect_source_task_metrics_source_record_poll_rate gauge
+kafka_connect_source_task_metrics_source_record_poll_rate{connector="my-source",host="`+server.URL+`",task="0"} 12.5
+`), "kafka_connect_connect_worker_metrics_connector_count", "kafka_connect_jolokia_up", "kafka_connect_source_task_metrics_source_record_poll_rate")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Should export jolokia as down without failing the poll of the host", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				if req.URL.Port() == "8778" {
+					return nil, errors.New("connection refused")
+				}
+				response := httptest.NewRecorder()
+				response.Write([]byte(`{}`))
+				return response.Result(), nil
+			},
+		}
+		targets := newTargets(roundTripper, []string{"http://test-host1:8083"})
+		targets[0].JolokiaCollector = targets[0].Collector
+		targets[0].Jolokia = &config.Jolokia{Port: 8778, Path: "/jolokia"}
+
+		poller := poller.New(targets, time.Minute)
+		poller.Poll()
+		exporter := New(poller)
+
+		err := testutil.CollectAndCompare(exporter, strings.NewReader(`
+# HELP kafka_connect_jolokia_up Whether the last read of the MBeans of the host with Jolokia succeeded (1) or failed (0)
+# TYPE kafka_connect_jolokia_up gauge
+kafka_connect_jolokia_up{host="http://test-host1:8083"} 0
+# HELP kafka_connect_up Whether the last poll of the host succeeded (1) or failed (0)
+# TYPE kafka_connect_up gauge
+kafka_connect_up{host="http://test-host1:8083"} 1
+`), "kafka_connect_jolokia_up", "kafka_connect_up")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Should export the offsets and progress of sink and source connectors", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				switch req.URL.Path {
+				case "/connectors":
+					response.Write([]byte(`{"orders-sink": {}, "file-source": {}}`))
+				case "/connectors/orders-sink/offsets":
+					response.Write([]byte(`{"offsets": [{"partition": {"kafka_topic": "orders", "kafka_partition": 1}, "offset": {"kafka_offset": 42}}]}`))
+				case "/connectors/file-source/offsets":
+					response.Write([]byte(`{"offsets": [{"partition": {"filename": "/data/input.txt"}, "offset": {"position": 1024, "file": "input.txt"}}]}`))
+				default:
+					response.WriteHeader(http.StatusNotFound)
+				}
+				return response.Result(), nil
+			},
+		}
+		targets := newTargets(roundTripper, []string{"http://test-host1"})
+		targets[0].Offsets = &config.Offsets{MaxPartitions: 100, StallThreshold: time.Hour}
+
+		poller := poller.New(targets, time.Minute)
+		poller.Poll()
+		exporter := New(poller)
+
+		err := testutil.CollectAndCompare(exporter, strings.NewReader(`
+# HELP kafka_connect_connector_offset_partitions Number of partitions with offsets of the connector, including the partitions beyond the export limit
+# TYPE kafka_connect_connector_offset_partitions gauge
+kafka_connect_connector_offset_partitions{connector="file-source",host="http://test-host1"} 1
+kafka_connect_connector_offset_partitions{connector="orders-sink",host="http://test-host1"} 1
+# HELP kafka_connect_sink_partition_offset Offset of the topic partition committed by the sink connector
+# TYPE kafka_connect_sink_partition_offset gauge
+kafka_connect_sink_partition_offset{connector="orders-sink",host="http://test-host1",partition="1",topic="orders"} 42
+# HELP kafka_connect_source_partition_offset Numeric field of the offset of the source partition committed by the source connector, the partition as JSON
+# TYPE kafka_connect_source_partition_offset gauge
+kafka_connect_source_partition_offset{connector="file-source",host="http://test-host1",key="position",partition="{\"filename\":\"/data/input.txt\"}"} 1024
+# HELP kafka_connect_connector_seconds_since_progress Seconds since any offset of the connector changed, as of the last poll
+# TYPE kafka_connect_connector_seconds_since_progress gauge
+kafka_connect_connector_seconds_since_progress{connector="file-source",host="http://test-host1"} 0
+kafka_connect_connector_seconds_since_progress{connector="orders-sink",host="http://test-host1"} 0
+# HELP kafka_connect_connector_stalled Whether a task of the connector is running while its offsets did not change for the stall threshold (1) or not (0)
+# TYPE kafka_connect_connector_stalled gauge
+kafka_connect_connector_stalled{connector="file-source",host="http://test-host1"} 0
+kafka_connect_connector_stalled{connector="orders-sink",host="http://test-host1"} 0
+`), "kafka_connect_connector_offset_partitions", "kafka_connect_sink_partition_offset", "kafka_connect_source_partition_offset",
+			"kafka_connect_connector_seconds_since_progress", "kafka_connect_connector_stalled")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Should export the active topics of connectors", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				switch req.URL.Path {
+				case "/connectors":
+					response.Write([]byte(`{"orders-sink": {}, "idle-sink": {}}`))
+				case "/connectors/orders-sink/topics":
+					response.Write([]byte(`{"orders-sink": {"topics": ["orders", "refunds"]}}`))
+				case "/connectors/idle-sink/topics":
+					response.Write([]byte(`{"idle-sink": {"topics": []}}`))
+				default:
+					response.WriteHeader(http.StatusNotFound)
+				}
+				return response.Result(), nil
+			},
+		}
+		targets := newTargets(roundTripper, []string{"http://test-host1"})
+		targets[0].Topics = &config.Topics{}
+
+		poller := poller.New(targets, time.Minute)
+		poller.Poll()
+		exporter := New(poller)
+
+		err := testutil.CollectAndCompare(exporter, strings.NewReader(`
+# HELP kafka_connect_connector_topic_info Topic used by the connector since its creation or the last reset of its active topics, always 1
+# TYPE kafka_connect_connector_topic_info gauge
+kafka_connect_connector_topic_info{connector="orders-sink",host="http://test-host1",topic="orders"} 1
+kafka_connect_connector_topic_info{connector="orders-sink",host="http://test-host1",topic="refunds"} 1
+`), "kafka_connect_connector_topic_info")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Should add the static labels of the cluster to host metrics", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				response.Write([]byte(`{}`))
+				return response.Result(), nil
+			},
+		}
+
+		targets := newTargets(roundTripper, []string{"http://test-host1", "http://test-host2"})
+		targets[0].Labels = map[string]string{"env": "prod", "team": "data"}
+		targets[1].Labels = map[string]string{"env": "staging"}
+
+		poller := poller.New(targets, time.Minute)
+		poller.Poll()
+		exporter := New(poller)
+
+		err := testutil.CollectAndCompare(exporter, strings.NewReader(`
+# HELP kafka_connect_up Whether the last poll of the host succeeded (1) or failed (0)
+# TYPE kafka_connect_up gauge
+kafka_connect_up{env="prod",host="http://test-host1",team="data"} 1
+kafka_connect_up{env="staging",host="http://test-host2",team=""} 1
+`), "kafka_connect_up")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Should export the host as down when API fails", func(t *testing.T) {
 		mockHosts := []string{"http://test-host1"}
-		config.KafkaConnectHosts = mockHosts
 
 		roundTripper := &mockRoundTripper{
 			roundTripFunc: func(req *http.Request) (*http.Response, error) {
@@ -100,19 +572,57 @@ func TestCollect(t *testing.T) {
 			},
 		}
 
-		exporter := New(collector.New(&http.Client{Transport: roundTripper}))
+		poller := poller.New(newTargets(roundTripper, mockHosts), time.Minute)
+		poller.Poll()
+		exporter := New(poller)
 
-		ch := make(chan prometheus.Metric, 10)
-		go func() {
-			exporter.Collect(ch)
-			close(ch)
-		}()
+		err := testutil.CollectAndCompare(exporter, strings.NewReader(`
+# HELP kafka_connect_up Whether the last poll of the host succeeded (1) or failed (0)
+# TYPE kafka_connect_up gauge
+kafka_connect_up{host="http://test-host1"} 0
+# HELP kafka_connect_scrape_errors_total Total number of failed polls of the host by kind (`+"`dial`, `tls`, `connection`, `timeout`, `status`, `decode`"+`)
+# TYPE kafka_connect_scrape_errors_total counter
+kafka_connect_scrape_errors_total{host="http://test-host1",kind="connection"} 0
+kafka_connect_scrape_errors_total{host="http://test-host1",kind="decode"} 0
+kafka_connect_scrape_errors_total{host="http://test-host1",kind="dial"} 0
+kafka_connect_scrape_errors_total{host="http://test-host1",kind="status"} 1
+kafka_connect_scrape_errors_total{host="http://test-host1",kind="timeout"} 0
+kafka_connect_scrape_errors_total{host="http://test-host1",kind="tls"} 0
+`), "kafka_connect_up", "kafka_connect_scrape_errors_total", "kafka_connect_connector_total")
+		assert.NoError(t, err)
+	})
 
-		collected := false
-		for range ch {
-			collected = true
+	t.Run("Should classify transport errors by kind", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				if req.URL.Host == "test-host1" {
+					return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+				}
+				return nil, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+			},
 		}
 
-		assert.False(t, collected)
+		poller := poller.New(newTargets(roundTripper, []string{"http://test-host1", "http://test-host2"}), time.Minute)
+		poller.Poll()
+		poller.Poll()
+		exporter := New(poller)
+
+		err := testutil.CollectAndCompare(exporter, strings.NewReader(`
+# HELP kafka_connect_scrape_errors_total Total number of failed polls of the host by kind (`+"`dial`, `tls`, `connection`, `timeout`, `status`, `decode`"+`)
+# TYPE kafka_connect_scrape_errors_total counter
+kafka_connect_scrape_errors_total{host="http://test-host1",kind="connection"} 0
+kafka_connect_scrape_errors_total{host="http://test-host1",kind="decode"} 0
+kafka_connect_scrape_errors_total{host="http://test-host1",kind="dial"} 2
+kafka_connect_scrape_errors_total{host="http://test-host1",kind="status"} 0
+kafka_connect_scrape_errors_total{host="http://test-host1",kind="timeout"} 0
+kafka_connect_scrape_errors_total{host="http://test-host1",kind="tls"} 0
+kafka_connect_scrape_errors_total{host="http://test-host2",kind="connection"} 2
+kafka_connect_scrape_errors_total{host="http://test-host2",kind="decode"} 0
+kafka_connect_scrape_errors_total{host="http://test-host2",kind="dial"} 0
+kafka_connect_scrape_errors_total{host="http://test-host2",kind="status"} 0
+kafka_connect_scrape_errors_total{host="http://test-host2",kind="timeout"} 0
+kafka_connect_scrape_errors_total{host="http://test-host2",kind="tls"} 0
+`), "kafka_connect_scrape_errors_total")
+		assert.NoError(t, err)
 	})
 }
@@ -1,5 +1,10 @@
 package exporter
 
+import "github.com/prometheus/client_golang/prometheus"
+
+// Sends a metric with the static labels of the host appended to the given label values
+type metricFunc func(desc *prometheus.Desc, valueType prometheus.ValueType, value float64, labelValues ...string)
+
 type ConnectorStatusMetric struct {
 	Name                string
 	UnAssignedTaskCount int
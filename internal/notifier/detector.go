@@ -0,0 +1,85 @@
+package notifier
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+)
+
+const (
+	connectorInstance = -1
+	maxTraceLines     = 5
+	maxTraceLen       = 1024
+)
+
+// The state of the connectors and tasks of a host, by connector and task
+func instanceStates(host *poller.HostSnapshot) map[instanceKey]instanceState {
+	states := map[instanceKey]instanceState{}
+	for name, connector := range host.Connectors {
+		if connector == nil || connector.Status == nil {
+			continue
+		}
+		status := connector.Status
+		states[instanceKey{connector: name, task: connectorInstance}] = instanceState{state: status.Connector.State, workerID: status.Connector.WorkerID}
+		for _, task := range status.Tasks {
+			states[instanceKey{connector: name, task: task.ID}] = instanceState{state: task.State, workerID: task.WorkerID, trace: task.Trace}
+		}
+	}
+	return states
+}
+
+// Compare the states of a cluster between two polls.
+// Deleted connectors and tasks are not reported, as kafka connect no longer knows their state.
+func diff(host *poller.HostSnapshot, previous, current map[instanceKey]instanceState) []Event {
+	var events []Event
+	for key, state := range current {
+		old, ok := previous[key]
+		if ok && old.state == state.state {
+			continue
+		}
+		event := Event{
+			Time:      host.Time,
+			Cluster:   host.Cluster,
+			Host:      host.Host,
+			Connector: key.connector,
+			WorkerID:  state.workerID,
+			OldState:  old.state,
+			NewState:  state.state,
+		}
+		if key.task != connectorInstance {
+			task := key.task
+			event.Task = &task
+		}
+		if state.trace != "" {
+			event.Exception = collector.RootException(state.trace)
+			event.Trace = excerpt(state.trace)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// Shorten a stack trace to its first lines, which name the exception, to keep payloads small
+func excerpt(trace string) string {
+	lines := strings.SplitN(trace, "\n", maxTraceLines+1)
+	truncated := len(lines) > maxTraceLines
+	if truncated {
+		lines = lines[:maxTraceLines]
+	}
+	result := strings.Join(lines, "\n")
+	if len(result) > maxTraceLen {
+		// cut at the start of a rune, so that the excerpt stays valid UTF-8
+		end := maxTraceLen
+		for end > 0 && !utf8.RuneStart(result[end]) {
+			end--
+		}
+		result = result[:end]
+		truncated = true
+	}
+	if truncated {
+		result += "\n..."
+	}
+	return result
+}
@@ -0,0 +1,111 @@
+package notifier
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+)
+
+// Detects state changes of connectors and tasks between consecutive snapshots and sends them to webhooks.
+// The first snapshot of a cluster only records the states, so that a restart of the exporter does not report every connector.
+type Notifier struct {
+	webhooks    []*webhook
+	dedupWindow time.Duration
+
+	// only accessed by the goroutine running the poller
+	states map[string]map[instanceKey]instanceState
+	sent   map[dedupKey]time.Time
+}
+
+func New(settings config.Notifications) (*Notifier, error) {
+	var webhooks []*webhook
+	for _, webhookSettings := range settings.Webhooks {
+		webhook, err := newWebhook(webhookSettings)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return &Notifier{
+		webhooks:    webhooks,
+		dedupWindow: settings.DedupWindow,
+		states:      map[string]map[instanceKey]instanceState{},
+		sent:        map[dedupKey]time.Time{},
+	}, nil
+}
+
+// Send the queued events of every webhook until the context is cancelled
+func (n *Notifier) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, webhook := range n.webhooks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			webhook.run(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// Queue an event for every state change since the previous snapshot.
+// Clusters without a healthy host keep their previous states until they can be polled again,
+// the states of clusters that are no longer polled are dropped.
+func (n *Notifier) Notify(snapshot *poller.Snapshot) {
+	for _, host := range snapshot.ClusterHosts() {
+		current := instanceStates(host)
+		previous, ok := n.states[host.Cluster]
+		n.states[host.Cluster] = current
+		if !ok {
+			continue
+		}
+
+		for _, event := range diff(host, previous, current) {
+			if n.duplicate(event) {
+				continue
+			}
+			for _, webhook := range n.webhooks {
+				if webhook.accepts(event) {
+					webhook.enqueue(event)
+				}
+			}
+		}
+	}
+	n.expire(snapshot.Time)
+	n.forget(snapshot)
+}
+
+// Whether the same change of the same connector or task was sent within the dedup window
+func (n *Notifier) duplicate(event Event) bool {
+	key := dedupKey{cluster: event.Cluster, instance: instanceKey{connector: event.Connector, task: connectorInstance}, oldState: event.OldState, newState: event.NewState}
+	if event.Task != nil {
+		key.instance.task = *event.Task
+	}
+	if sent, ok := n.sent[key]; ok && event.Time.Sub(sent) < n.dedupWindow {
+		return true
+	}
+	n.sent[key] = event.Time
+	return false
+}
+
+func (n *Notifier) forget(snapshot *poller.Snapshot) {
+	polled := map[string]bool{}
+	for _, host := range snapshot.Hosts {
+		polled[host.Cluster] = true
+	}
+	for cluster := range n.states {
+		if !polled[cluster] {
+			delete(n.states, cluster)
+		}
+	}
+}
+
+func (n *Notifier) expire(now time.Time) {
+	for key, sent := range n.sent {
+		if now.Sub(sent) >= n.dedupWindow {
+			delete(n.sent, key)
+		}
+	}
+}
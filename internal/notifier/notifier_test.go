@@ -0,0 +1,232 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	"github.com/stretchr/testify/assert"
+)
+
+const trace = "org.apache.kafka.connect.errors.ConnectException: failed\n\tat Task.run\nCaused by: java.net.ConnectException: refused"
+
+// A webhook server that records the request bodies and responds with the given status codes in order, then 200
+type webhookServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	bodies   []string
+	statuses []int
+}
+
+func newWebhookServer(t *testing.T, statuses ...int) *webhookServer {
+	server := &webhookServer{statuses: statuses}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		server.bodies = append(server.bodies, string(body))
+		if len(server.statuses) > 0 {
+			w.WriteHeader(server.statuses[0])
+			server.statuses = server.statuses[1:]
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *webhookServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.bodies...)
+}
+
+func retries(value int) *int {
+	return &value
+}
+
+func newNotifier(t *testing.T, webhooks ...config.Webhook) *Notifier {
+	for i := range webhooks {
+		webhooks[i].Timeout = time.Second
+		webhooks[i].RetryBackoff = time.Millisecond
+		if webhooks[i].MaxRetries == nil {
+			webhooks[i].MaxRetries = retries(0)
+		}
+		if webhooks[i].ContentType == "" {
+			webhooks[i].ContentType = "application/json"
+		}
+	}
+	notifier, err := New(config.Notifications{DedupWindow: time.Minute, Webhooks: webhooks})
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go notifier.Run(ctx)
+	return notifier
+}
+
+func newSnapshot(now time.Time, taskState string) *poller.Snapshot {
+	status := &collector.ConnectorStatus{Name: "connector1", Tasks: []collector.ConnectorTaskStatus{{ID: 0, State: taskState, WorkerID: "worker-1:8083"}}}
+	status.Connector.State = "RUNNING"
+	if taskState == "FAILED" {
+		status.Tasks[0].Trace = trace
+	}
+	return &poller.Snapshot{Time: now, Hosts: []*poller.HostSnapshot{
+		{Cluster: "prod", Host: "http://connect-1", Time: now, Connectors: map[string]*collector.ExpandedConnector{"connector1": {Status: status}}},
+	}}
+}
+
+func TestNotify(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Should post state changes since the previous snapshot", func(t *testing.T) {
+		server := newWebhookServer(t)
+		notifier := newNotifier(t, config.Webhook{Name: "test", URL: server.URL})
+
+		notifier.Notify(newSnapshot(now, "RUNNING"))
+		notifier.Notify(newSnapshot(now.Add(time.Second), "FAILED"))
+
+		assert.Eventually(t, func() bool { return len(server.received()) == 1 }, time.Second, 5*time.Millisecond)
+		var event Event
+		assert.Nil(t, json.Unmarshal([]byte(server.received()[0]), &event))
+		task := 0
+		assert.Equal(t, Event{
+			Time:      now.Add(time.Second),
+			Cluster:   "prod",
+			Host:      "http://connect-1",
+			Connector: "connector1",
+			Task:      &task,
+			WorkerID:  "worker-1:8083",
+			OldState:  "RUNNING",
+			NewState:  "FAILED",
+			Exception: "java.net.ConnectException",
+			Trace:     trace,
+		}, event)
+	})
+
+	t.Run("Should send an identical state change only once within the dedup window", func(t *testing.T) {
+		server := newWebhookServer(t)
+		notifier := newNotifier(t, config.Webhook{Name: "test", URL: server.URL, States: []string{"FAILED"}})
+
+		for i, state := range []string{"RUNNING", "FAILED", "RUNNING", "FAILED"} {
+			notifier.Notify(newSnapshot(now.Add(time.Duration(i)*time.Second), state))
+		}
+		notifier.Notify(newSnapshot(now.Add(2*time.Minute), "RUNNING"))
+		notifier.Notify(newSnapshot(now.Add(3*time.Minute), "FAILED"))
+
+		assert.Eventually(t, func() bool { return len(server.received()) == 2 }, time.Second, 5*time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		assert.Len(t, server.received(), 2)
+	})
+
+	t.Run("Should render the payload with the template of the webhook", func(t *testing.T) {
+		server := newWebhookServer(t)
+		notifier := newNotifier(t, config.Webhook{Name: "test", URL: server.URL, Template: `{"text": {{ printf "%s is %s: %s" .Connector .NewState .Exception | json }}}`})
+
+		notifier.Notify(newSnapshot(now, "RUNNING"))
+		notifier.Notify(newSnapshot(now.Add(time.Second), "FAILED"))
+
+		assert.Eventually(t, func() bool { return len(server.received()) == 1 }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, `{"text": "connector1 is FAILED: java.net.ConnectException"}`, server.received()[0])
+	})
+
+	t.Run("Should retry on server errors", func(t *testing.T) {
+		server := newWebhookServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+		notifier := newNotifier(t, config.Webhook{Name: "test", URL: server.URL, MaxRetries: retries(3)})
+
+		notifier.Notify(newSnapshot(now, "RUNNING"))
+		notifier.Notify(newSnapshot(now.Add(time.Second), "PAUSED"))
+
+		assert.Eventually(t, func() bool { return len(server.received()) == 3 }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("Should not retry on client errors", func(t *testing.T) {
+		server := newWebhookServer(t, http.StatusBadRequest)
+		notifier := newNotifier(t, config.Webhook{Name: "test", URL: server.URL, MaxRetries: retries(3)})
+
+		notifier.Notify(newSnapshot(now, "RUNNING"))
+		notifier.Notify(newSnapshot(now.Add(time.Second), "PAUSED"))
+
+		assert.Eventually(t, func() bool { return len(server.received()) == 1 }, time.Second, 5*time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		assert.Len(t, server.received(), 1)
+	})
+}
+
+func TestPost(t *testing.T) {
+	t.Run("Should not return the url of the webhook in errors", func(t *testing.T) {
+		server := newWebhookServer(t)
+		server.Close()
+		webhook, err := newWebhook(config.Webhook{Name: "slack", URL: server.URL + "/services/secret", MaxRetries: retries(0)})
+		assert.Nil(t, err)
+
+		retryable, err := webhook.post(context.Background(), []byte(`{}`))
+		assert.True(t, retryable)
+		assert.ErrorContains(t, err, "Failed to call webhook slack: ")
+		assert.NotContains(t, err.Error(), "secret")
+		assert.NotContains(t, err.Error(), server.URL)
+	})
+}
+
+func TestForget(t *testing.T) {
+	t.Run("Should forget the states of clusters that are no longer polled", func(t *testing.T) {
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		notifier := newNotifier(t)
+
+		notifier.Notify(newSnapshot(now, "RUNNING"))
+		assert.Contains(t, notifier.states, "prod")
+
+		// a cluster without a healthy host keeps its states
+		notifier.Notify(&poller.Snapshot{Time: now, Hosts: []*poller.HostSnapshot{{Cluster: "prod", Err: assert.AnError}}})
+		assert.Contains(t, notifier.states, "prod")
+
+		notifier.Notify(&poller.Snapshot{Time: now})
+		assert.Empty(t, notifier.states)
+	})
+}
+
+func TestContentType(t *testing.T) {
+	t.Run("Should send the configured content type", func(t *testing.T) {
+		var contentType string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contentType = r.Header.Get("Content-Type")
+		}))
+		defer server.Close()
+		webhook, err := newWebhook(config.Webhook{Name: "test", URL: server.URL, Template: "{{ .Connector }} is {{ .NewState }}", ContentType: "text/plain", MaxRetries: retries(0)})
+		assert.Nil(t, err)
+
+		_, err = webhook.post(context.Background(), []byte("connector1 is FAILED"))
+		assert.Nil(t, err)
+		assert.Equal(t, "text/plain", contentType)
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Run("Should return an error for an invalid template", func(t *testing.T) {
+		_, err := New(config.Notifications{Webhooks: []config.Webhook{{Name: "test", URL: "http://test", Template: "{{ .Connector "}}})
+		assert.ErrorContains(t, err, "Invalid template of webhook test")
+	})
+}
+
+func TestExcerpt(t *testing.T) {
+	t.Run("Should keep the first lines of a trace", func(t *testing.T) {
+		assert.Equal(t, trace, excerpt(trace))
+		assert.Equal(t, "1\n2\n3\n4\n5\n...", excerpt("1\n2\n3\n4\n5\n6\n7"))
+		assert.Equal(t, strings.Repeat("a", maxTraceLen)+"\n...", excerpt(strings.Repeat("a", 2*maxTraceLen)))
+	})
+
+	t.Run("Should not split a multi-byte character", func(t *testing.T) {
+		result := excerpt("a" + strings.Repeat("é", maxTraceLen))
+		assert.True(t, utf8.ValidString(result))
+		assert.Equal(t, "a"+strings.Repeat("é", (maxTraceLen-1)/2)+"\n...", result)
+	})
+}
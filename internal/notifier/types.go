@@ -0,0 +1,40 @@
+package notifier
+
+import "time"
+
+// A change of the state of a connector or one of its tasks between two poll cycles
+type Event struct {
+	Time      time.Time `json:"time"`
+	Cluster   string    `json:"cluster"`
+	Host      string    `json:"host"`
+	Connector string    `json:"connector"`
+	// nil for a change of the connector itself
+	Task     *int   `json:"task"`
+	WorkerID string `json:"worker_id"`
+	// empty for a connector or task created since the previous poll
+	OldState  string `json:"old_state"`
+	NewState  string `json:"new_state"`
+	Exception string `json:"exception,omitempty"`
+	// the first lines of the stack trace of a failed task
+	Trace string `json:"trace,omitempty"`
+}
+
+// Identifies the connector or task that an event is about
+type instanceKey struct {
+	connector string
+	// -1 for the connector itself
+	task int
+}
+
+type instanceState struct {
+	state    string
+	workerID string
+	trace    string
+}
+
+type dedupKey struct {
+	cluster  string
+	instance instanceKey
+	oldState string
+	newState string
+}
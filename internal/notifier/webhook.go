@@ -0,0 +1,159 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"text/template"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	applicationError "github.com/Ecube-Labs/kafka-connect-exporter/pkg/application-error"
+	"github.com/Ecube-Labs/kafka-connect-exporter/pkg/logger"
+)
+
+// number of events buffered per webhook while a request is in flight
+const queueSize = 100
+
+var templateFuncs = template.FuncMap{
+	// encode a value as JSON, e.g. to embed a stack trace in a JSON payload
+	"json": func(value any) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+type webhook struct {
+	name         string
+	url          string
+	headers      map[string]config.Secret
+	template     *template.Template
+	contentType  string
+	states       []string
+	maxRetries   int
+	retryBackoff time.Duration
+	client       *http.Client
+	queue        chan Event
+}
+
+// The settings must have their defaults applied, as config.Load does
+func newWebhook(settings config.Webhook) (*webhook, error) {
+	var tmpl *template.Template
+	if settings.Template != "" {
+		var err error
+		if tmpl, err = template.New(settings.Name).Funcs(templateFuncs).Parse(settings.Template); err != nil {
+			return nil, applicationError.New(http.StatusBadRequest, fmt.Sprintf("Invalid template of webhook %s: %s", settings.Name, err.Error()), "")
+		}
+	}
+	return &webhook{
+		name:         settings.Name,
+		url:          settings.URL,
+		headers:      settings.Headers,
+		template:     tmpl,
+		contentType:  settings.ContentType,
+		states:       settings.States,
+		maxRetries:   *settings.MaxRetries,
+		retryBackoff: settings.RetryBackoff,
+		client:       &http.Client{Timeout: settings.Timeout},
+		queue:        make(chan Event, queueSize),
+	}, nil
+}
+
+func (w *webhook) accepts(event Event) bool {
+	return len(w.states) == 0 || slices.Contains(w.states, event.NewState)
+}
+
+// Queue the event without blocking the poller. Events are dropped when the webhook cannot keep up.
+func (w *webhook) enqueue(event Event) {
+	select {
+	case w.queue <- event:
+	default:
+		logger.Log("error", fmt.Sprintf("Dropping event of connector %s for webhook %s, the queue is full", event.Connector, w.name))
+	}
+}
+
+// Send the queued events one after another until the context is cancelled
+func (w *webhook) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-w.queue:
+			if err := w.send(ctx, event); err != nil {
+				logger.Log("error", applicationError.UnWrap(err).Stack)
+			}
+		}
+	}
+}
+
+// Post the event, retrying on transport errors, 429 and 5xx responses
+func (w *webhook) send(ctx context.Context, event Event) error {
+	body, err := w.render(event)
+	if err != nil {
+		return err
+	}
+
+	backoff := w.retryBackoff
+	for attempt := 0; ; attempt++ {
+		retryable, err := w.post(ctx, body)
+		if err == nil || !retryable || attempt >= w.maxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (w *webhook) render(event Event) ([]byte, error) {
+	if w.template == nil {
+		return json.Marshal(event)
+	}
+	var body bytes.Buffer
+	if err := w.template.Execute(&body, event); err != nil {
+		return nil, applicationError.New(http.StatusInternalServerError, fmt.Sprintf("Failed to render template of webhook %s: %s", w.name, err.Error()), "")
+	}
+	return body.Bytes(), nil
+}
+
+func (w *webhook) post(ctx context.Context, body []byte) (bool, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, applicationError.New(http.StatusInternalServerError, fmt.Sprintf("Failed to create request of webhook %s: %s", w.name, requestCause(err)), "")
+	}
+	request.Header.Set("Content-Type", w.contentType)
+	for name, value := range w.headers {
+		request.Header.Set(name, string(value))
+	}
+
+	response, err := w.client.Do(request)
+	if err != nil {
+		return true, applicationError.New(http.StatusServiceUnavailable, fmt.Sprintf("Failed to call webhook %s: %s", w.name, requestCause(err)), "")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		responseBody, _ := io.ReadAll(response.Body)
+		retryable := response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+		return retryable, applicationError.New(response.StatusCode, fmt.Sprintf("Failed to call webhook %s. status: %d, body: %s", w.name, response.StatusCode, string(responseBody)), "")
+	}
+	return false, nil
+}
+
+// The cause of a failed request without its url, because the url of a webhook, e.g. of Slack or Teams, is a secret
+func requestCause(err error) string {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err.Error()
+	}
+	return err.Error()
+}
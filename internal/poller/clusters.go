@@ -0,0 +1,114 @@
+package poller
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/pkg/logger"
+)
+
+// The successfully polled hosts of a cluster and their targets, in the order of the targets
+type clusterHosts struct {
+	hosts   []*HostSnapshot
+	targets []Target
+}
+
+// The offsets of a cluster read through one of its hosts
+type clusterOffsets struct {
+	host        *HostSnapshot
+	target      Target
+	offsets     map[string]*ConnectorOffsets
+	unsupported bool
+}
+
+// Read the offsets and topics of every cluster through one of its hosts, the clusters in parallel.
+// Kafka connect stores both by cluster, so every host of the cluster gets the same offsets and topics.
+func (p *Poller) pollClusters(hosts []*HostSnapshot, targets []Target) {
+	clusters := groupClusters(hosts, targets)
+
+	offsets := make([]*clusterOffsets, len(clusters))
+	topics := make([]map[string][]string, len(clusters))
+	var wg sync.WaitGroup
+	for i, cluster := range clusters {
+		if index, ok := p.offsetsHost(cluster); ok {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result := &clusterOffsets{host: cluster.hosts[index], target: cluster.targets[index]}
+				result.offsets, result.unsupported = readOffsets(result.target, result.host.Connectors)
+				offsets[i] = result
+			}()
+		}
+		if cluster.targets[0].Topics != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				topics[i] = readTopics(cluster.targets[0], cluster.hosts[0].Connectors)
+			}()
+		}
+	}
+	wg.Wait()
+
+	for i, cluster := range clusters {
+		if topics[i] != nil {
+			for _, host := range cluster.hosts {
+				host.Topics = topics[i]
+			}
+		}
+
+		result := offsets[i]
+		if result == nil {
+			continue
+		}
+		if result.unsupported {
+			p.offsetsUnsupported[result.host.Host] = workerVersion(result.host)
+			logger.Log("error", fmt.Sprintf("The offsets endpoint is not supported by host %s, the offsets of cluster %s are no longer read from it", result.host.Host, result.host.Cluster))
+			continue
+		}
+		for _, host := range cluster.hosts {
+			host.Offsets = result.offsets
+		}
+		p.trackProgress(result.host, result.target)
+	}
+}
+
+// The index of the first host of the cluster with offsets that supports the offsets endpoint
+func (p *Poller) offsetsHost(cluster *clusterHosts) (int, bool) {
+	for i, host := range cluster.hosts {
+		if cluster.targets[i].Offsets == nil {
+			continue
+		}
+		if version, ok := p.offsetsUnsupported[host.Host]; ok && version == workerVersion(host) {
+			continue
+		}
+		delete(p.offsetsUnsupported, host.Host)
+		return i, true
+	}
+	return 0, false
+}
+
+func groupClusters(hosts []*HostSnapshot, targets []Target) []*clusterHosts {
+	index := map[string]*clusterHosts{}
+	var clusters []*clusterHosts
+	for i, host := range hosts {
+		if host.Err != nil {
+			continue
+		}
+		cluster, ok := index[host.Cluster]
+		if !ok {
+			cluster = &clusterHosts{}
+			index[host.Cluster] = cluster
+			clusters = append(clusters, cluster)
+		}
+		cluster.hosts = append(cluster.hosts, host)
+		cluster.targets = append(cluster.targets, targets[i])
+	}
+	return clusters
+}
+
+func workerVersion(host *HostSnapshot) string {
+	if host.Worker == nil {
+		return ""
+	}
+	return host.Worker.Version
+}
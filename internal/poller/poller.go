@@ -0,0 +1,340 @@
+package poller
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
+	applicationError "github.com/Ecube-Labs/kafka-connect-exporter/pkg/application-error"
+	"github.com/Ecube-Labs/kafka-connect-exporter/pkg/logger"
+)
+
+// Polls every kafka connect host in the background and keeps the result of the latest cycle as a snapshot,
+// so that scrapes never wait on the kafka connect REST API.
+type Poller struct {
+	targets     []Target
+	discoverers []Discoverer
+	interval    time.Duration
+	snapshot    atomic.Pointer[Snapshot]
+	listeners   []func(*Snapshot)
+	// only accessed by the goroutine running Poll
+	lastSuccess map[string]time.Time
+	errors      map[string]map[string]uint64
+	// the worker of every task of a host in its last successful poll
+	assignments map[string]map[string]string
+	rebalances  map[string]uint64
+	// the last progress of the offsets of every connector of a cluster
+	progress map[string]map[string]offsetProgress
+	// the worker version of the hosts without the offsets endpoint, which are asked again once their version changes
+	offsetsUnsupported map[string]string
+	// the time of the last reset of the topics of every cluster
+	topicsReset map[string]time.Time
+	// the clusters whose topics are being reset in the background
+	resetting   map[string]bool
+	resettingMu sync.Mutex
+}
+
+func New(targets []Target, interval time.Duration) *Poller {
+	return &Poller{
+		targets:     targets,
+		interval:    interval,
+		lastSuccess: map[string]time.Time{},
+		errors:      map[string]map[string]uint64{},
+		assignments: map[string]map[string]string{},
+		rebalances:  map[string]uint64{},
+		progress:    map[string]map[string]offsetProgress{},
+		topicsReset: map[string]time.Time{},
+		resetting:   map[string]bool{},
+
+		offsetsUnsupported: map[string]string{},
+	}
+}
+
+// Poll immediately and then on every interval until the context is cancelled
+func (p *Poller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.Poll()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect every host once and publish the result as the latest snapshot.
+// Poll must not be called concurrently.
+func (p *Poller) Poll() {
+	targets := p.Targets()
+	hosts := make([]*HostSnapshot, len(targets))
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+
+		go func(i int, t Target) {
+			defer wg.Done()
+			hosts[i] = p.pollHost(t)
+		}(i, target)
+	}
+	wg.Wait()
+
+	p.pollClusters(hosts, targets)
+
+	current := make(map[string]bool, len(hosts))
+	clusters := map[string]bool{}
+	for _, host := range hosts {
+		current[host.Host] = true
+		clusters[host.Cluster] = true
+		if host.Err == nil {
+			p.lastSuccess[host.Host] = host.Time
+		}
+		host.LastSuccess = p.lastSuccess[host.Host]
+		host.Errors = p.countError(host.Host, host.Err)
+		host.Rebalances = p.countRebalance(host)
+	}
+	// forget the hosts that are no longer discovered
+	for host := range p.errors {
+		if !current[host] {
+			delete(p.errors, host)
+			delete(p.lastSuccess, host)
+			delete(p.assignments, host)
+			delete(p.rebalances, host)
+			delete(p.offsetsUnsupported, host)
+		}
+	}
+	// and the clusters without any host, e.g. discovered clusters that were removed
+	for cluster := range p.progress {
+		if !clusters[cluster] {
+			delete(p.progress, cluster)
+		}
+	}
+
+	snapshot := &Snapshot{Time: time.Now(), Hosts: hosts}
+	p.snapshot.Store(snapshot)
+
+	for _, listener := range p.listeners {
+		listener(snapshot)
+	}
+
+	p.resetTopics(snapshot, targets)
+}
+
+// The static targets followed by the targets of every discoverer.
+// A host found by more than one source is only polled once.
+func (p *Poller) Targets() []Target {
+	targets := append([]Target{}, p.targets...)
+	for _, discoverer := range p.discoverers {
+		targets = append(targets, discoverer.Targets()...)
+	}
+
+	seen := make(map[string]bool, len(targets))
+	unique := targets[:0]
+	for _, target := range targets {
+		if !seen[target.Host] {
+			seen[target.Host] = true
+			unique = append(unique, target)
+		}
+	}
+	return unique
+}
+
+// Poll the targets of the discoverer in addition to the static targets.
+// AddDiscoverer must be called before the poller is started.
+func (p *Poller) AddDiscoverer(discoverer Discoverer) {
+	p.discoverers = append(p.discoverers, discoverer)
+}
+
+// Call the listener with every published snapshot. Listeners are called one after another by the goroutine
+// running Poll, so they delay the next poll and must not modify the snapshot.
+// AddListener must be called before the poller is started.
+func (p *Poller) AddListener(listener func(*Snapshot)) {
+	p.listeners = append(p.listeners, listener)
+}
+
+func (p *Poller) pollHost(target Target) *HostSnapshot {
+	start := time.Now()
+	connectors, filtered, err := target.Collector.GetExpandedConnectors(target.Host, target.Filter)
+	if err != nil {
+		logger.Log("error", applicationError.UnWrap(err).Stack)
+	}
+
+	var plugins []collector.ConnectorPlugin
+	var worker *collector.WorkerInfo
+	if err == nil {
+		// the version of connectors and workers is optional, so a failure does not fail the poll of the host
+		var pluginsErr, workerErr error
+		if plugins, pluginsErr = target.Collector.GetConnectorPlugins(target.Host); pluginsErr != nil {
+			logger.Log("error", applicationError.UnWrap(pluginsErr).Stack)
+		}
+		if worker, workerErr = target.Collector.GetWorkerInfo(target.Host); workerErr != nil {
+			logger.Log("error", applicationError.UnWrap(workerErr).Stack)
+		}
+	}
+
+	var jmx []collector.JMXMetric
+	var jmxErr error
+	if err == nil && target.Jolokia != nil {
+		if jmx, jmxErr = p.readMBeans(target); jmxErr != nil {
+			logger.Log("error", applicationError.UnWrap(jmxErr).Stack)
+		}
+	}
+
+	return &HostSnapshot{
+		Cluster:    target.Cluster,
+		Host:       target.Host,
+		Collector:  target.Collector,
+		Labels:     target.Labels,
+		Time:       time.Now(),
+		Connectors: connectors,
+		Plugins:    plugins,
+		Worker:     worker,
+		JMX:        jmx,
+		JMXErr:     jmxErr,
+		Filtered:   filtered,
+		Err:        err,
+		Duration:   time.Since(start),
+	}
+}
+
+// Read the offsets of every connector through the host. The offsets are optional, so a connector whose offsets
+// cannot be read is left out without failing the poll. A host that answers every request with 404 or 405
+// is older than kafka 3.5 and reported as unsupported instead of logging the failure of every connector.
+func readOffsets(target Target, connectors map[string]*collector.ExpandedConnector) (map[string]*ConnectorOffsets, bool) {
+	offsets, errs := collector.ReadConnectors(slices.Collect(maps.Keys(connectors)), func(name string) (*ConnectorOffsets, error) {
+		connectorOffsets, err := target.Collector.GetConnectorOffsets(target.Host, name)
+		if err != nil {
+			return nil, err
+		}
+		return limitOffsets(connectorOffsets.Offsets, target.Offsets.MaxPartitions), nil
+	})
+
+	unsupported := len(offsets) == 0 && len(errs) > 0
+	for _, err := range errs {
+		if code := applicationError.UnWrap(err).Code; code != http.StatusNotFound && code != http.StatusMethodNotAllowed {
+			unsupported = false
+		}
+	}
+	if !unsupported {
+		for _, err := range errs {
+			logger.Log("error", applicationError.UnWrap(err).Stack)
+		}
+	}
+	return offsets, unsupported
+}
+
+// Sort the offsets by partition, so that the same partitions are kept on every poll when the limit applies.
+// The digest covers the offsets of all partitions, so that progress beyond the limit is detected.
+func limitOffsets(offsets []collector.ConnectorOffset, maxPartitions int) *ConnectorOffsets {
+	type keyedOffset struct {
+		key    string
+		offset collector.ConnectorOffset
+	}
+	keyed := make([]keyedOffset, len(offsets))
+	for i, offset := range offsets {
+		key, _ := json.Marshal(offset.Partition)
+		keyed[i] = keyedOffset{key: string(key), offset: offset}
+	}
+	sort.Slice(keyed, func(i, j int) bool {
+		return keyed[i].key < keyed[j].key
+	})
+
+	digest := fnv.New64a()
+	limited := &ConnectorOffsets{Partitions: len(offsets)}
+	for i, keyedOffset := range keyed {
+		offset, _ := json.Marshal(keyedOffset.offset.Offset)
+		digest.Write([]byte(keyedOffset.key))
+		digest.Write(offset)
+		if i < maxPartitions {
+			limited.Offsets = append(limited.Offsets, keyedOffset.offset)
+		}
+	}
+	limited.digest = digest.Sum64()
+	return limited
+}
+
+// Read the MBeans of the host, without the MBeans of connectors excluded by the filter
+func (p *Poller) readMBeans(target Target) ([]collector.JMXMetric, error) {
+	url, err := jolokiaURL(target.Host, target.Jolokia)
+	if err != nil {
+		return nil, applicationError.New(http.StatusBadRequest, err.Error(), "")
+	}
+	metrics, err := target.JolokiaCollector.GetJMXMetrics(url, target.Jolokia.MBeans)
+	if err != nil {
+		return nil, err
+	}
+
+	// not nil after a successful read, even without metrics
+	matching := make([]collector.JMXMetric, 0, len(metrics))
+	for _, metric := range metrics {
+		if metric.Connector == "" || target.Filter.Match(metric.Connector) {
+			matching = append(matching, metric)
+		}
+	}
+	return matching, nil
+}
+
+// Count the failure of a host and return a copy of its counters for the snapshot
+func (p *Poller) countError(host string, err error) map[string]uint64 {
+	counts, ok := p.errors[host]
+	if !ok {
+		counts = make(map[string]uint64, len(collector.FailureKinds))
+		for _, kind := range collector.FailureKinds {
+			counts[kind] = 0
+		}
+		p.errors[host] = counts
+	}
+	if err != nil {
+		counts[collector.FailureKind(err)]++
+	}
+
+	snapshot := make(map[string]uint64, len(counts))
+	for kind, count := range counts {
+		snapshot[kind] = count
+	}
+	return snapshot
+}
+
+// Count a rebalance when a task of the host is assigned to another worker than in its last successful poll.
+// A task without a worker keeps its previous worker, so that a move through the unassigned state is counted once.
+func (p *Poller) countRebalance(host *HostSnapshot) uint64 {
+	if host.Err != nil {
+		return p.rebalances[host.Host]
+	}
+
+	previous := p.assignments[host.Host]
+	current := host.taskAssignments()
+	rebalanced := false
+	for task, worker := range current {
+		previousWorker, ok := previous[task]
+		switch {
+		case worker == "" && ok:
+			current[task] = previousWorker
+		case worker != "" && ok && previousWorker != "" && worker != previousWorker:
+			rebalanced = true
+		}
+	}
+	p.assignments[host.Host] = current
+
+	if rebalanced {
+		p.rebalances[host.Host]++
+	}
+	return p.rebalances[host.Host]
+}
+
+// The latest snapshot, or nil when no poll has completed yet
+func (p *Poller) Snapshot() *Snapshot {
+	return p.snapshot.Load()
+}
@@ -0,0 +1,587 @@
+package poller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockRoundTripper struct {
+	roundTripFunc func(req *http.Request) (*http.Response, error)
+}
+
+func (m *mockRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return m.roundTripFunc(req)
+}
+
+func newTargets(roundTripper http.RoundTripper, hosts []string) []Target {
+	collector := collector.New(&http.Client{Transport: roundTripper})
+	targets := make([]Target, len(hosts))
+	for i, host := range hosts {
+		targets[i] = Target{Cluster: "default", Host: host, Collector: collector}
+	}
+	return targets
+}
+
+func TestNew(t *testing.T) {
+	t.Run("Should return a new poller without a snapshot", func(t *testing.T) {
+		poller := New(newTargets(http.DefaultTransport, []string{"http://test-host1"}), time.Minute)
+		assert.NotNil(t, poller)
+		assert.Nil(t, poller.Snapshot())
+	})
+}
+
+func TestPoll(t *testing.T) {
+	t.Run("Should publish a snapshot of every host", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				if req.URL.Host == "test-host2" {
+					response.WriteHeader(http.StatusInternalServerError)
+					return response.Result(), nil
+				}
+				response.Write([]byte(`{"connector1": {"status": {"name": "connector1", "connector": {"state": "RUNNING"}}}}`))
+				return response.Result(), nil
+			},
+		}
+
+		poller := New(newTargets(roundTripper, []string{"http://test-host1", "http://test-host2"}), time.Minute)
+		poller.Poll()
+
+		snapshot := poller.Snapshot()
+		assert.NotNil(t, snapshot)
+		assert.Len(t, snapshot.Hosts, 2)
+
+		assert.Equal(t, "http://test-host1", snapshot.Hosts[0].Host)
+		assert.Nil(t, snapshot.Hosts[0].Err)
+		assert.Equal(t, "RUNNING", snapshot.Hosts[0].Connectors["connector1"].Status.Connector.State)
+		assert.Equal(t, snapshot.Hosts[0].Time, snapshot.Hosts[0].LastSuccess)
+
+		assert.Equal(t, "http://test-host2", snapshot.Hosts[1].Host)
+		assert.NotNil(t, snapshot.Hosts[1].Err)
+		assert.True(t, snapshot.Hosts[1].LastSuccess.IsZero())
+	})
+
+	t.Run("Should keep the last success time of a host that starts failing", func(t *testing.T) {
+		var failing atomic.Bool
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				if failing.Load() {
+					response.WriteHeader(http.StatusInternalServerError)
+					return response.Result(), nil
+				}
+				response.Write([]byte(`{}`))
+				return response.Result(), nil
+			},
+		}
+
+		poller := New(newTargets(roundTripper, []string{"http://test-host1"}), time.Minute)
+		poller.Poll()
+		first := poller.Snapshot()
+
+		failing.Store(true)
+		poller.Poll()
+		second := poller.Snapshot()
+
+		assert.NotSame(t, first, second)
+		assert.Nil(t, first.Hosts[0].Err)
+		assert.NotNil(t, second.Hosts[0].Err)
+		assert.Equal(t, first.Hosts[0].Time, second.Hosts[0].LastSuccess)
+	})
+}
+
+func TestRebalances(t *testing.T) {
+	t.Run("Should count the polls in which tasks are assigned to another worker", func(t *testing.T) {
+		var body atomic.Value
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				response.Write([]byte(body.Load().(string)))
+				return response.Result(), nil
+			},
+		}
+		status := func(worker0, worker1 string) string {
+			return `{"connector1": {"status": {"name": "connector1", "connector": {"state": "RUNNING", "worker_id": "worker1:8083"}, "tasks": [` +
+				`{"id": 0, "state": "RUNNING", "worker_id": "` + worker0 + `"}, {"id": 1, "state": "RUNNING", "worker_id": "` + worker1 + `"}]}}}`
+		}
+
+		poller := New(newTargets(roundTripper, []string{"http://test-host1"}), time.Minute)
+		for _, step := range []struct {
+			body       string
+			rebalances uint64
+		}{
+			{status("worker1:8083", "worker2:8083"), 0},
+			{status("worker1:8083", "worker2:8083"), 0},
+			// both tasks move in the same poll
+			{status("worker2:8083", "worker1:8083"), 1},
+			// a task without a worker keeps its previous worker
+			{status("worker2:8083", ""), 1},
+			{status("worker2:8083", "worker1:8083"), 1},
+			{status("worker2:8083", "worker3:8083"), 2},
+		} {
+			body.Store(step.body)
+			poller.Poll()
+			assert.Equal(t, step.rebalances, poller.Snapshot().Hosts[0].Rebalances)
+		}
+	})
+}
+
+func TestOffsets(t *testing.T) {
+	t.Run("Should read the offsets of every connector limited to the max partitions", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				switch req.URL.Path {
+				case "/connectors":
+					response.Write([]byte(`{"sink": {}, "old": {}}`))
+				case "/connectors/sink/offsets":
+					response.Write([]byte(`{"offsets": [
+						{"partition": {"kafka_topic": "orders", "kafka_partition": 2}, "offset": {"kafka_offset": 30}},
+						{"partition": {"kafka_topic": "orders", "kafka_partition": 0}, "offset": {"kafka_offset": 10}},
+						{"partition": {"kafka_topic": "orders", "kafka_partition": 1}, "offset": {"kafka_offset": 20}}
+					]}`))
+				default:
+					response.WriteHeader(http.StatusNotFound)
+				}
+				return response.Result(), nil
+			},
+		}
+		targets := newTargets(roundTripper, []string{"http://test-host1"})
+		targets[0].Offsets = &config.Offsets{MaxPartitions: 2}
+
+		poller := New(targets, time.Minute)
+		poller.Poll()
+
+		host := poller.Snapshot().Hosts[0]
+		assert.Nil(t, host.Err)
+		assert.Len(t, host.Offsets, 1)
+		assert.Equal(t, []collector.ConnectorOffset{
+			{Partition: map[string]any{"kafka_topic": "orders", "kafka_partition": float64(0)}, Offset: map[string]any{"kafka_offset": float64(10)}},
+			{Partition: map[string]any{"kafka_topic": "orders", "kafka_partition": float64(1)}, Offset: map[string]any{"kafka_offset": float64(20)}},
+		}, host.Offsets["sink"].Offsets)
+		assert.Equal(t, 3, host.Offsets["sink"].Partitions)
+		assert.Equal(t, host.Time, host.Offsets["sink"].LastProgress)
+	})
+
+	t.Run("Should read the offsets once per cluster and share them with every host of the cluster", func(t *testing.T) {
+		var requests atomic.Int32
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				switch req.URL.Path {
+				case "/connectors":
+					response.Write([]byte(`{"sink-1": {}, "sink-2": {}}`))
+				case "/connectors/sink-1/offsets", "/connectors/sink-2/offsets":
+					requests.Add(1)
+					response.Write([]byte(`{"offsets": [{"partition": {"kafka_topic": "orders", "kafka_partition": 0}, "offset": {"kafka_offset": 10}}]}`))
+				default:
+					response.WriteHeader(http.StatusNotFound)
+				}
+				return response.Result(), nil
+			},
+		}
+		targets := newTargets(roundTripper, []string{"http://test-host1", "http://test-host2"})
+		for i := range targets {
+			targets[i].Offsets = &config.Offsets{MaxPartitions: 100}
+		}
+
+		poller := New(targets, time.Minute)
+		poller.Poll()
+
+		hosts := poller.Snapshot().Hosts
+		assert.Equal(t, int32(2), requests.Load())
+		assert.Len(t, hosts[0].Offsets, 2)
+		assert.Equal(t, hosts[0].Offsets, hosts[1].Offsets)
+	})
+
+	t.Run("Should stop reading the offsets from a host without the offsets endpoint until its version changes", func(t *testing.T) {
+		var requests atomic.Int32
+		version := "3.4.0"
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				switch req.URL.Path {
+				case "/connectors":
+					response.Write([]byte(`{"sink-1": {}, "sink-2": {}}`))
+				case "/":
+					response.Write([]byte(`{"version": "` + version + `"}`))
+				case "/connectors/sink-1/offsets", "/connectors/sink-2/offsets":
+					requests.Add(1)
+					response.WriteHeader(http.StatusNotFound)
+				default:
+					response.WriteHeader(http.StatusNotFound)
+				}
+				return response.Result(), nil
+			},
+		}
+		targets := newTargets(roundTripper, []string{"http://test-host1"})
+		targets[0].Offsets = &config.Offsets{MaxPartitions: 100}
+
+		poller := New(targets, time.Minute)
+		poller.Poll()
+		assert.Equal(t, int32(2), requests.Load())
+
+		poller.Poll()
+		assert.Equal(t, int32(2), requests.Load())
+		assert.Nil(t, poller.Snapshot().Hosts[0].Offsets)
+
+		version = "3.5.0"
+		poller.Poll()
+		assert.Equal(t, int32(4), requests.Load())
+	})
+}
+
+func TestTrackProgress(t *testing.T) {
+	connector := func(taskState string) *collector.ExpandedConnector {
+		return &collector.ExpandedConnector{Status: &collector.ConnectorStatus{Tasks: []collector.ConnectorTaskStatus{{State: taskState}}}}
+	}
+	offsets := func(kafkaOffset int) *ConnectorOffsets {
+		return limitOffsets([]collector.ConnectorOffset{
+			{Partition: map[string]any{"kafka_topic": "orders", "kafka_partition": float64(0)}, Offset: map[string]any{"kafka_offset": float64(0)}},
+			{Partition: map[string]any{"kafka_topic": "orders", "kafka_partition": float64(1)}, Offset: map[string]any{"kafka_offset": float64(kafkaOffset)}},
+		}, 1)
+	}
+	settings := &config.Offsets{StallThreshold: time.Hour, StallThresholds: []config.StallThreshold{{Connectors: []string{"fast-.*"}, Threshold: time.Minute}}}
+	target, err := NewClusterTarget(config.Cluster{Name: "default", Offsets: settings})
+	assert.Nil(t, err)
+
+	t.Run("Should mark a running connector as stalled when its offsets do not change for the threshold", func(t *testing.T) {
+		poller := New(nil, time.Minute)
+		start := time.Now()
+		poll := func(elapsed time.Duration, kafkaOffset int, taskState string) *ConnectorOffsets {
+			host := &HostSnapshot{
+				Host:       "http://test-host1",
+				Time:       start.Add(elapsed),
+				Connectors: map[string]*collector.ExpandedConnector{"sink": connector(taskState)},
+				Offsets:    map[string]*ConnectorOffsets{"sink": offsets(kafkaOffset)},
+			}
+			poller.trackProgress(host, target)
+			return host.Offsets["sink"]
+		}
+
+		first := poll(0, 1, "RUNNING")
+		assert.Equal(t, start, first.LastProgress)
+		assert.False(t, first.Stalled)
+
+		// the offset of the partition beyond the limit of one partition changes
+		moved := poll(30*time.Minute, 2, "RUNNING")
+		assert.Equal(t, start.Add(30*time.Minute), moved.LastProgress)
+
+		assert.False(t, poll(89*time.Minute, 2, "RUNNING").Stalled)
+		stalled := poll(90*time.Minute, 2, "RUNNING")
+		assert.Equal(t, start.Add(30*time.Minute), stalled.LastProgress)
+		assert.True(t, stalled.Stalled)
+
+		// a paused connector does not commit offsets
+		assert.False(t, poll(91*time.Minute, 2, "PAUSED").Stalled)
+	})
+
+	t.Run("Should apply the threshold of the first matching connectors pattern", func(t *testing.T) {
+		poller := New(nil, time.Minute)
+		start := time.Now()
+		for _, elapsed := range []time.Duration{0, 2 * time.Minute} {
+			host := &HostSnapshot{
+				Host:       "http://test-host1",
+				Time:       start.Add(elapsed),
+				Connectors: map[string]*collector.ExpandedConnector{"fast-sink": connector("RUNNING"), "slow-sink": connector("RUNNING")},
+				Offsets:    map[string]*ConnectorOffsets{"fast-sink": offsets(1), "slow-sink": offsets(1)},
+			}
+			poller.trackProgress(host, target)
+
+			assert.Equal(t, elapsed > 0, host.Offsets["fast-sink"].Stalled)
+			assert.False(t, host.Offsets["slow-sink"].Stalled)
+		}
+	})
+}
+
+func TestTopics(t *testing.T) {
+	newRoundTripper := func(resets *atomic.Int32) *mockRoundTripper {
+		return &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				switch req.URL.Path {
+				case "/connectors":
+					response.Write([]byte(`{"sink": {}, "untracked": {}}`))
+				case "/connectors/sink/topics":
+					response.Write([]byte(`{"sink": {"topics": ["payments", "orders"]}}`))
+				case "/connectors/sink/topics/reset", "/connectors/untracked/topics/reset":
+					resets.Add(1)
+					response.WriteHeader(http.StatusAccepted)
+				default:
+					response.WriteHeader(http.StatusNotFound)
+				}
+				return response.Result(), nil
+			},
+		}
+	}
+
+	t.Run("Should read the sorted active topics of every connector", func(t *testing.T) {
+		var resets atomic.Int32
+		targets := newTargets(newRoundTripper(&resets), []string{"http://test-host1"})
+		targets[0].Topics = &config.Topics{}
+
+		poller := New(targets, time.Minute)
+		poller.Poll()
+		poller.Poll()
+
+		assert.Equal(t, map[string][]string{"sink": {"orders", "payments"}}, poller.Snapshot().Hosts[0].Topics)
+		assert.Equal(t, int32(0), resets.Load())
+	})
+
+	t.Run("Should read the topics once per cluster and share them with every host of the cluster", func(t *testing.T) {
+		var resets, reads atomic.Int32
+		roundTripper := newRoundTripper(&resets)
+		read := roundTripper.roundTripFunc
+		roundTripper.roundTripFunc = func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path == "/connectors/sink/topics" {
+				reads.Add(1)
+			}
+			return read(req)
+		}
+		targets := newTargets(roundTripper, []string{"http://test-host1", "http://test-host2"})
+		for i := range targets {
+			targets[i].Topics = &config.Topics{}
+		}
+
+		poller := New(targets, time.Minute)
+		poller.Poll()
+
+		hosts := poller.Snapshot().Hosts
+		assert.Equal(t, int32(1), reads.Load())
+		assert.Equal(t, map[string][]string{"sink": {"orders", "payments"}}, hosts[0].Topics)
+		assert.Equal(t, hosts[0].Topics, hosts[1].Topics)
+	})
+
+	t.Run("Should reset the topics of every connector of the cluster once per interval through one host", func(t *testing.T) {
+		var resets atomic.Int32
+		targets := newTargets(newRoundTripper(&resets), []string{"http://test-host1", "http://test-host2"})
+		for i := range targets {
+			targets[i].Topics = &config.Topics{ResetInterval: time.Nanosecond}
+		}
+
+		poller := New(targets, time.Minute)
+		// the first poll starts the interval
+		poller.Poll()
+		assert.Equal(t, int32(0), resets.Load())
+
+		poller.Poll()
+		assert.Eventually(t, func() bool { return resets.Load() == 2 }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("Should not reset the topics of a cluster while its last reset is running", func(t *testing.T) {
+		var resets atomic.Int32
+		release := make(chan struct{})
+		roundTripper := newRoundTripper(&resets)
+		read := roundTripper.roundTripFunc
+		roundTripper.roundTripFunc = func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodPut {
+				<-release
+			}
+			return read(req)
+		}
+		targets := newTargets(roundTripper, []string{"http://test-host1"})
+		targets[0].Topics = &config.Topics{ResetInterval: time.Nanosecond}
+
+		poller := New(targets, time.Minute)
+		poller.Poll()
+		poller.Poll()
+		// the reset of the second poll is still waiting
+		poller.Poll()
+		close(release)
+
+		assert.Eventually(t, func() bool { return resets.Load() == 2 }, time.Second, 5*time.Millisecond)
+		assert.Never(t, func() bool { return resets.Load() > 2 }, 50*time.Millisecond, 5*time.Millisecond)
+	})
+}
+
+type mockDiscoverer struct {
+	targets []Target
+}
+
+func (m *mockDiscoverer) Targets() []Target {
+	return m.targets
+}
+
+func TestAddDiscoverer(t *testing.T) {
+	t.Run("Should poll the discovered targets next to the static targets", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				response.Write([]byte(`{}`))
+				return response.Result(), nil
+			},
+		}
+		targets := newTargets(roundTripper, []string{"http://test-host1", "http://test-host2", "http://test-host3"})
+		discoverer := &mockDiscoverer{targets: targets[1:]}
+
+		poller := New(targets[:2], time.Minute)
+		poller.AddDiscoverer(discoverer)
+		poller.Poll()
+
+		snapshot := poller.Snapshot()
+		assert.Len(t, snapshot.Hosts, 3)
+		assert.Equal(t, "http://test-host3", snapshot.Hosts[2].Host)
+
+		// the state of hosts that are no longer discovered is dropped
+		discoverer.targets = nil
+		poller.Poll()
+		assert.Len(t, poller.Snapshot().Hosts, 2)
+		assert.NotContains(t, poller.errors, "http://test-host3")
+		assert.NotContains(t, poller.lastSuccess, "http://test-host3")
+	})
+}
+
+func TestAddListener(t *testing.T) {
+	t.Run("Should call the listeners with the published snapshot", func(t *testing.T) {
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				response := httptest.NewRecorder()
+				response.Write([]byte(`{}`))
+				return response.Result(), nil
+			},
+		}
+
+		poller := New(newTargets(roundTripper, []string{"http://test-host1"}), time.Minute)
+		var received *Snapshot
+		poller.AddListener(func(snapshot *Snapshot) { received = snapshot })
+		poller.Poll()
+
+		assert.Same(t, poller.Snapshot(), received)
+	})
+}
+
+func TestClusterHosts(t *testing.T) {
+	t.Run("Should return the first successfully polled host of every cluster", func(t *testing.T) {
+		snapshot := &Snapshot{Hosts: []*HostSnapshot{
+			{Cluster: "prod", Host: "http://connect-1", Err: assert.AnError},
+			{Cluster: "prod", Host: "http://connect-2"},
+			{Cluster: "prod", Host: "http://connect-3"},
+			{Cluster: "staging", Host: "http://connect-staging", Err: assert.AnError},
+		}}
+
+		hosts := snapshot.ClusterHosts()
+		assert.Len(t, hosts, 1)
+		assert.Equal(t, "http://connect-2", hosts[0].Host)
+	})
+}
+
+func TestWorkers(t *testing.T) {
+	t.Run("Should count the connectors and tasks of every worker by state", func(t *testing.T) {
+		status := func(name, worker string, tasks ...collector.ConnectorTaskStatus) *collector.ExpandedConnector {
+			connector := &collector.ExpandedConnector{Status: &collector.ConnectorStatus{Name: name, Tasks: tasks}}
+			connector.Status.Connector.State = "RUNNING"
+			connector.Status.Connector.WorkerID = worker
+			return connector
+		}
+		host := &HostSnapshot{Connectors: map[string]*collector.ExpandedConnector{
+			"connector1": status("connector1", "worker1:8083",
+				collector.ConnectorTaskStatus{ID: 0, State: "RUNNING", WorkerID: "worker1:8083"},
+				collector.ConnectorTaskStatus{ID: 1, State: "FAILED", WorkerID: "worker2:8083"},
+				collector.ConnectorTaskStatus{ID: 2, State: "UNASSIGNED"}),
+			"connector2": status("connector2", "worker1:8083",
+				collector.ConnectorTaskStatus{ID: 0, State: "RUNNING", WorkerID: "worker1:8083"}),
+			"connector3": {},
+		}}
+
+		assert.Equal(t, map[string]*WorkerLoad{
+			"worker1:8083": {Connectors: map[string]int{"RUNNING": 2}, Tasks: map[string]int{"RUNNING": 2}},
+			"worker2:8083": {Connectors: map[string]int{}, Tasks: map[string]int{"FAILED": 1}},
+		}, host.Workers())
+	})
+}
+
+func TestNewClusterTarget(t *testing.T) {
+	t.Run("Should fail on an invalid pattern of the stall thresholds", func(t *testing.T) {
+		_, err := NewClusterTarget(config.Cluster{Name: "prod", Offsets: &config.Offsets{StallThresholds: []config.StallThreshold{{Connectors: []string{"sink-("}}}}})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Should read the MBeans with the auth of the jolokia agent instead of the auth of the cluster", func(t *testing.T) {
+		var restAuth, jolokiaAuth string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/jolokia" {
+				jolokiaAuth = r.Header.Get("Authorization")
+				w.Write([]byte(`[{"request": {"mbean": "kafka.connect:type=connect-worker-metrics,*"}, "value": {"kafka.connect:type=connect-worker-metrics": {"connector-count": 1}}, "status": 200}]`))
+				return
+			}
+			restAuth = r.Header.Get("Authorization")
+			w.Write([]byte(`{}`))
+		}))
+		defer server.Close()
+
+		port, _ := strconv.Atoi(server.URL[strings.LastIndex(server.URL, ":")+1:])
+		target, err := NewClusterTarget(config.Cluster{
+			Name: "prod",
+			Auth: config.Auth{Bearer: &config.BearerAuth{Token: "rest-token"}},
+			Jolokia: &config.Jolokia{
+				Port:   port,
+				Path:   "/jolokia",
+				MBeans: []string{"kafka.connect:type=connect-worker-metrics,*"},
+				Auth:   config.Auth{Basic: &config.BasicAuth{Username: "jolokia", Password: "secret"}},
+			},
+		})
+		assert.Nil(t, err)
+		target.Host = server.URL
+
+		poller := New([]Target{target}, time.Minute)
+		poller.Poll()
+
+		host := poller.Snapshot().Hosts[0]
+		assert.Nil(t, host.JMXErr)
+		assert.Len(t, host.JMX, 1)
+		assert.Equal(t, "Bearer rest-token", restAuth)
+		assert.Equal(t, "Basic am9sb2tpYTpzZWNyZXQ=", jolokiaAuth)
+	})
+}
+
+func TestJolokiaURL(t *testing.T) {
+	t.Run("Should use the hostname and scheme of the REST API with the port and path of the agent", func(t *testing.T) {
+		url, err := jolokiaURL("https://connect-1:8443", &config.Jolokia{Port: 8778, Path: "/jolokia"})
+		assert.Nil(t, err)
+		assert.Equal(t, "https://connect-1:8778/jolokia", url)
+	})
+
+	t.Run("Should use the scheme of the agent when it is set", func(t *testing.T) {
+		url, err := jolokiaURL("https://connect-1:8443", &config.Jolokia{Port: 8778, Path: "/jolokia", Scheme: "http"})
+		assert.Nil(t, err)
+		assert.Equal(t, "http://connect-1:8778/jolokia", url)
+	})
+}
+
+func TestRun(t *testing.T) {
+	t.Run("Should poll on every interval until the context is cancelled", func(t *testing.T) {
+		var requestCount atomic.Int32
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				requestCount.Add(1)
+				response := httptest.NewRecorder()
+				response.Write([]byte(`{}`))
+				return response.Result(), nil
+			},
+		}
+
+		poller := New(newTargets(roundTripper, []string{"http://test-host1"}), 10*time.Millisecond)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			poller.Run(ctx)
+			close(done)
+		}()
+
+		assert.Eventually(t, func() bool { return requestCount.Load() >= 3 }, time.Second, 5*time.Millisecond)
+		cancel()
+		<-done
+		assert.NotNil(t, poller.Snapshot())
+	})
+}
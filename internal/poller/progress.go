@@ -0,0 +1,83 @@
+package poller
+
+import (
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+)
+
+type offsetProgress struct {
+	digest uint64
+	time   time.Time
+}
+
+// The stall threshold of the connectors matching the patterns of the filter
+type StallThreshold struct {
+	Connectors *collector.Filter
+	Threshold  time.Duration
+}
+
+// Set the last progress of the offsets of every connector of the cluster of the host and whether the connector is stalled.
+// A connector whose offsets could not be read in this poll keeps its last progress.
+func (p *Poller) trackProgress(host *HostSnapshot, target Target) {
+	if host.Err != nil || target.Offsets == nil {
+		return
+	}
+
+	previous := p.progress[host.Cluster]
+	current := make(map[string]offsetProgress, len(host.Connectors))
+	for name := range host.Connectors {
+		progress, ok := previous[name]
+		offsets, read := host.Offsets[name]
+		if !read {
+			if ok {
+				current[name] = progress
+			}
+			continue
+		}
+		if !ok || progress.digest != offsets.digest {
+			progress = offsetProgress{digest: offsets.digest, time: host.Time}
+		}
+		current[name] = progress
+
+		offsets.LastProgress = progress.time
+		offsets.Stalled = hasRunningTask(host.Connectors[name]) && host.Time.Sub(progress.time) >= stallThresholdOf(target.StallThresholds, target.Offsets.StallThreshold, name)
+	}
+	p.progress[host.Cluster] = current
+}
+
+// Compile the patterns of the stall thresholds, once per cluster
+func newStallThresholds(settings *config.Offsets) ([]StallThreshold, error) {
+	thresholds := make([]StallThreshold, 0, len(settings.StallThresholds))
+	for _, threshold := range settings.StallThresholds {
+		connectors, err := collector.NewFilter(threshold.Connectors, nil)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, StallThreshold{Connectors: connectors, Threshold: threshold.Threshold})
+	}
+	return thresholds, nil
+}
+
+// The threshold of the first thresholds whose connectors match, otherwise the default threshold
+func stallThresholdOf(thresholds []StallThreshold, defaultThreshold time.Duration, connector string) time.Duration {
+	for _, threshold := range thresholds {
+		if threshold.Connectors.Match(connector) {
+			return threshold.Threshold
+		}
+	}
+	return defaultThreshold
+}
+
+func hasRunningTask(connector *collector.ExpandedConnector) bool {
+	if connector == nil || connector.Status == nil {
+		return false
+	}
+	for _, task := range connector.Status.Tasks {
+		if task.State == "RUNNING" {
+			return true
+		}
+	}
+	return false
+}
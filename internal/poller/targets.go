@@ -0,0 +1,77 @@
+package poller
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/client"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+)
+
+// Build the targets of the static urls of the clusters. Hosts of the same cluster share one collector.
+func NewTargets(clusters []config.Cluster) ([]Target, error) {
+	var targets []Target
+	for _, cluster := range clusters {
+		clusterTarget, err := NewClusterTarget(cluster)
+		if err != nil {
+			return nil, err
+		}
+		for _, url := range cluster.StaticURLs() {
+			target := clusterTarget
+			target.Host = strings.TrimSuffix(url, "/")
+			targets = append(targets, target)
+		}
+	}
+	return targets, nil
+}
+
+// A target of the cluster without a host, shared by the targets of the cluster found by discovery
+func NewClusterTarget(cluster config.Cluster) (Target, error) {
+	httpClient, err := client.New(cluster)
+	if err != nil {
+		return Target{}, err
+	}
+	filter, err := collector.NewFilter(cluster.Filters.Include, cluster.Filters.Exclude)
+	if err != nil {
+		return Target{}, err
+	}
+	target := Target{
+		Cluster:   cluster.Name,
+		Labels:    cluster.Labels,
+		Collector: collector.New(httpClient),
+		Filter:    filter,
+		Jolokia:   cluster.Jolokia,
+		Offsets:   cluster.Offsets,
+		Topics:    cluster.Topics,
+	}
+	if cluster.Jolokia != nil {
+		jolokiaClient, err := client.New(config.Cluster{Name: cluster.Name, Timeout: cluster.Timeout, Auth: cluster.Jolokia.Auth, TLS: cluster.Jolokia.TLS})
+		if err != nil {
+			return Target{}, err
+		}
+		target.JolokiaCollector = collector.New(jolokiaClient)
+	}
+	if cluster.Offsets != nil {
+		if target.StallThresholds, err = newStallThresholds(cluster.Offsets); err != nil {
+			return Target{}, err
+		}
+	}
+	return target, nil
+}
+
+// The url of the Jolokia agent on the host of the REST API of a worker
+func jolokiaURL(host string, settings *config.Jolokia) (string, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return "", err
+	}
+	if settings.Scheme != "" {
+		u.Scheme = settings.Scheme
+	}
+	u.Host = net.JoinHostPort(u.Hostname(), strconv.Itoa(settings.Port))
+	u.Path = settings.Path
+	return u.String(), nil
+}
@@ -0,0 +1,97 @@
+package poller
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
+	applicationError "github.com/Ecube-Labs/kafka-connect-exporter/pkg/application-error"
+	"github.com/Ecube-Labs/kafka-connect-exporter/pkg/logger"
+)
+
+// Read the sorted topics that every connector has used through the host.
+// A connector without topics, e.g. when topic tracking is disabled on the workers, is logged and left out.
+func readTopics(target Target, connectors map[string]*collector.ExpandedConnector) map[string][]string {
+	topics, errs := collector.ReadConnectors(slices.Collect(maps.Keys(connectors)), func(name string) ([]string, error) {
+		connectorTopics, err := target.Collector.GetConnectorTopics(target.Host, name)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(connectorTopics)
+		return connectorTopics, nil
+	})
+	for _, err := range errs {
+		logger.Log("error", applicationError.UnWrap(err).Stack)
+	}
+	return topics
+}
+
+// Start the reset of the active topics of the connectors of every cluster whose reset interval has passed.
+// The topics are stored by the cluster, so they are reset through one host of the cluster, in the background
+// so that the next poll does not wait on it. A cluster whose last reset is still running is reset on a later poll.
+// The interval starts with the first successful poll of the cluster, so a restart of the exporter does not reset the topics.
+func (p *Poller) resetTopics(snapshot *Snapshot, targets []Target) {
+	settings := make(map[string]Target, len(targets))
+	for _, target := range targets {
+		settings[target.Host] = target
+	}
+
+	for _, host := range snapshot.ClusterHosts() {
+		topics := settings[host.Host].Topics
+		if topics == nil || topics.ResetInterval <= 0 {
+			continue
+		}
+
+		lastReset, ok := p.topicsReset[host.Cluster]
+		if !ok {
+			p.topicsReset[host.Cluster] = snapshot.Time
+			continue
+		}
+		if snapshot.Time.Sub(lastReset) < topics.ResetInterval || !p.startReset(host.Cluster) {
+			continue
+		}
+
+		p.topicsReset[host.Cluster] = snapshot.Time
+		go p.resetClusterTopics(host)
+	}
+
+	// forget the clusters that are no longer polled, e.g. discovered clusters that were removed
+	clusters := map[string]bool{}
+	for _, host := range snapshot.Hosts {
+		clusters[host.Cluster] = true
+	}
+	for cluster := range p.topicsReset {
+		if !clusters[cluster] {
+			delete(p.topicsReset, cluster)
+		}
+	}
+}
+
+// Mark the cluster as resetting, false when a reset of the cluster is already running
+func (p *Poller) startReset(cluster string) bool {
+	p.resettingMu.Lock()
+	defer p.resettingMu.Unlock()
+	if p.resetting[cluster] {
+		return false
+	}
+	p.resetting[cluster] = true
+	return true
+}
+
+func (p *Poller) resetClusterTopics(host *HostSnapshot) {
+	defer func() {
+		p.resettingMu.Lock()
+		delete(p.resetting, host.Cluster)
+		p.resettingMu.Unlock()
+	}()
+
+	reset, errs := collector.ReadConnectors(slices.Collect(maps.Keys(host.Connectors)), func(name string) (struct{}, error) {
+		return struct{}{}, host.Collector.ResetConnectorTopics(host.Host, name)
+	})
+	for _, err := range errs {
+		logger.Log("error", applicationError.UnWrap(err).Stack)
+	}
+	logger.Log("info", fmt.Sprintf("Reset the active topics of %d connectors of cluster %s", len(reset), host.Cluster))
+}
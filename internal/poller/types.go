@@ -0,0 +1,178 @@
+package poller
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+)
+
+// A kafka connect host to poll
+type Target struct {
+	Cluster   string
+	Host      string
+	Labels    map[string]string
+	Collector *collector.Collector
+	Filter    *collector.Filter
+	// read the MBeans of the host with Jolokia when set
+	Jolokia *config.Jolokia
+	// the collector of the Jolokia agent, with the auth and TLS of the agent
+	JolokiaCollector *collector.Collector
+	// read the offsets of the connectors when set
+	Offsets *config.Offsets
+	// the compiled stall thresholds of the offsets
+	StallThresholds []StallThreshold
+	// read the active topics of the connectors when set
+	Topics *config.Topics
+}
+
+// Provides targets that change at runtime, e.g. the pods of a kubernetes deployment.
+// Targets is called by the goroutine running the poller on every poll and must not block.
+type Discoverer interface {
+	Targets() []Target
+}
+
+// The result of one poll cycle. A snapshot is never modified once it has been published.
+type Snapshot struct {
+	Time  time.Time
+	Hosts []*HostSnapshot
+}
+
+type HostSnapshot struct {
+	Cluster string
+	Host    string
+	// the collector that polled the host, for follow-up requests
+	Collector  *collector.Collector
+	Labels     map[string]string
+	Time       time.Time
+	Connectors map[string]*collector.ExpandedConnector
+	Plugins    []collector.ConnectorPlugin
+	// the version and kafka cluster of the worker, nil when the root endpoint could not be read
+	Worker *collector.WorkerInfo
+	// numeric attributes of the kafka.connect MBeans, only read when jolokia is configured for the cluster.
+	// Not nil when the MBeans were read successfully.
+	JMX []collector.JMXMetric
+	// the failure to read the MBeans, which does not fail the poll of the host
+	JMXErr error
+	// the offsets of the connectors by name, only read when offsets are configured for the cluster.
+	// The offsets are read once per cluster, so all hosts of the cluster share them.
+	Offsets map[string]*ConnectorOffsets
+	// the sorted active topics of the connectors by name, only read when topics are configured for the cluster.
+	// The topics are read once per cluster, so all hosts of the cluster share them.
+	Topics map[string][]string
+	// number of connectors excluded by the filter of the target
+	Filtered int
+	Err      error
+	Duration time.Duration
+	// cumulative number of failed polls of the host by collector.FailureKind
+	Errors map[string]uint64
+	// zero when the host has never been polled successfully
+	LastSuccess time.Time
+	// cumulative number of polls in which a task of the host was assigned to another worker than in the previous poll
+	Rebalances uint64
+}
+
+// The offsets of a connector, sorted by partition and limited to the max partitions of the cluster
+type ConnectorOffsets struct {
+	Offsets []collector.ConnectorOffset
+	// number of partitions before the limit was applied
+	Partitions int
+	// the last time any offset of the connector changed, or the first poll of the connector
+	LastProgress time.Time
+	// whether a task of the connector is running while its offsets did not change for the stall threshold
+	Stalled bool
+	// hash of the offsets of all partitions, to detect progress between polls
+	digest uint64
+}
+
+// The number of connectors and tasks assigned to a worker by state
+type WorkerLoad struct {
+	Connectors map[string]int
+	Tasks      map[string]int
+}
+
+// Sorted names of the static labels of all hosts.
+// Hosts without a label report it as empty, so that every metric family has the same label names.
+func (s *Snapshot) LabelNames() []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, host := range s.Hosts {
+		for name := range host.Labels {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Values of the given static labels of the host, in the same order
+func (h *HostSnapshot) LabelValues(names []string) []string {
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = h.Labels[name]
+	}
+	return values
+}
+
+// The first successfully polled host of every cluster, in the order of the targets.
+// All hosts of a cluster report the same connectors, so one host is enough to act on a cluster.
+func (s *Snapshot) ClusterHosts() []*HostSnapshot {
+	seen := map[string]bool{}
+	var hosts []*HostSnapshot
+	for _, host := range s.Hosts {
+		if host.Err != nil || seen[host.Cluster] {
+			continue
+		}
+		seen[host.Cluster] = true
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// The connectors and tasks of the host by the id of the worker they are assigned to.
+// Instances without a worker, e.g. tasks that have never been assigned, are not counted.
+func (h *HostSnapshot) Workers() map[string]*WorkerLoad {
+	workers := map[string]*WorkerLoad{}
+	worker := func(id string) *WorkerLoad {
+		load, ok := workers[id]
+		if !ok {
+			load = &WorkerLoad{Connectors: map[string]int{}, Tasks: map[string]int{}}
+			workers[id] = load
+		}
+		return load
+	}
+
+	for _, connector := range h.Connectors {
+		if connector.Status == nil {
+			continue
+		}
+		if id := connector.Status.Connector.WorkerID; id != "" {
+			worker(id).Connectors[connector.Status.Connector.State]++
+		}
+		for _, task := range connector.Status.Tasks {
+			if task.WorkerID != "" {
+				worker(task.WorkerID).Tasks[task.State]++
+			}
+		}
+	}
+	return workers
+}
+
+// The worker of every task of the host by connector and task id
+func (h *HostSnapshot) taskAssignments() map[string]string {
+	assignments := map[string]string{}
+	for name, connector := range h.Connectors {
+		if connector.Status == nil {
+			continue
+		}
+		for _, task := range connector.Status.Tasks {
+			assignments[fmt.Sprintf("%s/%d", name, task.ID)] = task.WorkerID
+		}
+	}
+	return assignments
+}
@@ -0,0 +1,96 @@
+package probe
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	exporter "github.com/Ecube-Labs/kafka-connect-exporter/internal/exporter/prometheus"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Collects a single target given by the scraper on demand, in the style of the blackbox exporter.
+// The target is collected with the auth, TLS, timeout and filters of the module selected by the module query parameter.
+type Prober struct {
+	modules map[string]*module
+}
+
+type module struct {
+	// the target without a host, shared by all probes of the module
+	target         poller.Target
+	allowedTargets *collector.Filter
+}
+
+func New(modules map[string]config.Module) (*Prober, error) {
+	prober := &Prober{modules: map[string]*module{}}
+	for name, settings := range modules {
+		target, err := poller.NewClusterTarget(settings.Cluster(name))
+		if err != nil {
+			return nil, err
+		}
+		allowedTargets, err := collector.NewFilter(settings.AllowedTargets, nil)
+		if err != nil {
+			return nil, err
+		}
+		prober.modules[name] = &module{target: target, allowedTargets: allowedTargets}
+	}
+	return prober, nil
+}
+
+func (p *Prober) Handler() http.Handler {
+	return http.HandlerFunc(p.probe)
+}
+
+func (p *Prober) probe(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	target := strings.TrimSuffix(query.Get("target"), "/")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(w, fmt.Sprintf("Invalid target %q, expected http(s)://host:port", target), http.StatusBadRequest)
+		return
+	}
+
+	moduleName := query.Get("module")
+	if moduleName == "" {
+		moduleName = config.DefaultModuleName
+	}
+	module, ok := p.modules[moduleName]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown module %q", moduleName), http.StatusBadRequest)
+		return
+	}
+	if !module.allowedTargets.Match(target) {
+		http.Error(w, fmt.Sprintf("Target %q is not allowed by module %q", target, moduleName), http.StatusForbidden)
+		return
+	}
+
+	probeTarget := module.target
+	probeTarget.Host = target
+	// the interval is unused, as the poller is never run
+	targetPoller := poller.New([]poller.Target{probeTarget}, time.Minute)
+
+	start := time.Now()
+	targetPoller.Poll()
+	duration := time.Since(start)
+
+	probeSuccess := prometheus.NewGauge(prometheus.GaugeOpts{Name: "probe_success", Help: "Whether the probe of the target succeeded"})
+	probeDuration := prometheus.NewGauge(prometheus.GaugeOpts{Name: "probe_duration_seconds", Help: "Duration of the probe of the target"})
+	if targetPoller.Snapshot().Hosts[0].Err == nil {
+		probeSuccess.Set(1)
+	}
+	probeDuration.Set(duration.Seconds())
+
+	registry := exporter.NewRegistry(targetPoller)
+	registry.MustRegister(probeSuccess, probeDuration)
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
@@ -0,0 +1,95 @@
+package probe
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// A kafka connect worker that requires the given bearer token
+func newConnectServer(t *testing.T, token string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/connectors" {
+			w.Write([]byte(`{"connector1": {"status": {"name": "connector1", "connector": {"state": "RUNNING"}, "tasks": [{"id": 0, "state": "RUNNING"}]}}}`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newProber(t *testing.T) *Prober {
+	prober, err := New(map[string]config.Module{
+		config.DefaultModuleName: {Timeout: time.Second},
+		"prod": {
+			Timeout:        time.Second,
+			Labels:         map[string]string{"env": "prod"},
+			Auth:           config.Auth{Bearer: &config.BearerAuth{Token: "token"}},
+			AllowedTargets: []string{`http://127\.0\.0\.1:\d+`},
+		},
+	})
+	assert.Nil(t, err)
+	return prober
+}
+
+func probe(handler http.Handler, target, module string) *httptest.ResponseRecorder {
+	response := httptest.NewRecorder()
+	query := url.Values{"target": {target}, "module": {module}}
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/probe?"+query.Encode(), nil))
+	return response
+}
+
+func TestProbe(t *testing.T) {
+	handler := newProber(t).Handler()
+	server := newConnectServer(t, "token")
+
+	t.Run("Should collect the target with the settings of the module", func(t *testing.T) {
+		response := probe(handler, server.URL+"/", "prod")
+
+		assert.Equal(t, http.StatusOK, response.Code)
+		body := response.Body.String()
+		assert.Contains(t, body, "probe_success 1")
+		assert.Contains(t, body, fmt.Sprintf(`kafka_connect_up{env="prod",host=%q} 1`, server.URL))
+		assert.Contains(t, body, fmt.Sprintf(`kafka_connect_connector_running_total{connector="connector1",env="prod",host=%q} 1`, server.URL))
+	})
+
+	t.Run("Should report a failed probe", func(t *testing.T) {
+		// the default module has no credentials
+		response := probe(handler, server.URL, "")
+
+		assert.Equal(t, http.StatusOK, response.Code)
+		assert.Contains(t, response.Body.String(), "probe_success 0")
+		assert.Contains(t, response.Body.String(), fmt.Sprintf(`kafka_connect_up{host=%q} 0`, server.URL))
+	})
+
+	t.Run("Should reject invalid probes", func(t *testing.T) {
+		tests := []struct {
+			target string
+			module string
+			status int
+			body   string
+		}{
+			{"", "prod", http.StatusBadRequest, "target parameter is missing"},
+			{"connect:8083", "prod", http.StatusBadRequest, `Invalid target "connect:8083", expected http(s)://host:port`},
+			{server.URL, "staging", http.StatusBadRequest, `Unknown module "staging"`},
+			{"http://connect:8083", "prod", http.StatusForbidden, `Target "http://connect:8083" is not allowed by module "prod"`},
+		}
+
+		for _, test := range tests {
+			response := probe(handler, test.target, test.module)
+			assert.Equal(t, test.status, response.Code)
+			assert.Equal(t, test.body+"\n", response.Body.String())
+		}
+	})
+}
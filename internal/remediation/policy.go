@@ -0,0 +1,40 @@
+package remediation
+
+import (
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+)
+
+type policy struct {
+	connectors     *collector.Filter
+	maxAttempts    int
+	window         time.Duration
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+// The settings must have their defaults applied, as config.Load does
+func newPolicy(settings config.RestartPolicy) (*policy, error) {
+	connectors, err := collector.NewFilter(settings.Connectors, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &policy{
+		connectors:     connectors,
+		maxAttempts:    *settings.MaxAttempts,
+		window:         settings.Window,
+		initialBackoff: settings.InitialBackoff,
+		maxBackoff:     settings.MaxBackoff,
+	}, nil
+}
+
+// Delay before the next restart after the given number of restarts within the window
+func (p *policy) backoff(attempts int) time.Duration {
+	backoff := p.initialBackoff
+	for i := 1; i < attempts && backoff < p.maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, p.maxBackoff)
+}
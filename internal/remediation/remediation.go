@@ -0,0 +1,237 @@
+package remediation
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	applicationError "github.com/Ecube-Labs/kafka-connect-exporter/pkg/application-error"
+	"github.com/Ecube-Labs/kafka-connect-exporter/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// how many restart requests are sent at once
+const restartConcurrency = 4
+
+// Restarts failed connectors and tasks found in the snapshots of the poller, according to the restart policies.
+// It implements the prometheus.Collector interface to export its counters.
+type Remediator struct {
+	dryRun bool
+	// the filter of the connectors that are restarted, without the excluded connectors
+	filter        *collector.Filter
+	policies      []*policy
+	defaultPolicy *policy
+	now           func() time.Time
+
+	mu       sync.Mutex
+	states   map[connectorKey]*restartState
+	counters map[connectorKey]*restartCounters
+
+	// restarts run in the background, so that a slow worker does not delay the poller
+	slots    chan struct{}
+	restarts sync.WaitGroup
+
+	descAttempted *prometheus.Desc
+	descSucceeded *prometheus.Desc
+	descGivenUp   *prometheus.Desc
+}
+
+func New(settings config.Remediation) (*Remediator, error) {
+	filter, err := collector.NewFilter(nil, settings.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	defaultPolicy, err := newPolicy(settings.DefaultPolicy)
+	if err != nil {
+		return nil, err
+	}
+	var policies []*policy
+	for _, policySettings := range settings.Policies {
+		policy, err := newPolicy(policySettings)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+
+	labels := []string{"cluster", "connector", "dry_run"}
+	prefix := "kafka_connect_restarts"
+
+	return &Remediator{
+		dryRun:        settings.DryRun,
+		filter:        filter,
+		policies:      policies,
+		defaultPolicy: defaultPolicy,
+		now:           time.Now,
+		states:        map[connectorKey]*restartState{},
+		counters:      map[connectorKey]*restartCounters{},
+		slots:         make(chan struct{}, restartConcurrency),
+		descAttempted: prometheus.NewDesc(prefix+"_attempted_total", "Total number of automatic restarts of failed connectors and tasks", labels, nil),
+		descSucceeded: prometheus.NewDesc(prefix+"_succeeded_total", "Total number of automatic restarts accepted by kafka connect", labels, nil),
+		descGivenUp:   prometheus.NewDesc(prefix+"_given_up_total", "Total number of times the restart policy gave up on a failed connector", labels, nil),
+	}, nil
+}
+
+func (r *Remediator) policyFor(connector string) *policy {
+	for _, policy := range r.policies {
+		if policy.connectors.Match(connector) {
+			return policy
+		}
+	}
+	return r.defaultPolicy
+}
+
+// Restart every failed connector of the snapshot that is due according to its policy.
+// Connectors are restarted through the first healthy host of their cluster, in the background.
+// The state of connectors that are no longer in the snapshot is dropped.
+func (r *Remediator) Remediate(snapshot *poller.Snapshot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	for _, host := range snapshot.ClusterHosts() {
+		for name, connector := range host.Connectors {
+			key := connectorKey{cluster: host.Cluster, connector: name}
+			state := r.state(key)
+			policy := r.policyFor(name)
+			state.prune(now, policy.window)
+			if state.gaveUp && len(state.attempts) < policy.maxAttempts {
+				// the window of the attempts has passed, so the policy tries again
+				state.gaveUp = false
+			}
+
+			if !failed(connector.Status) {
+				// keep the attempts, so that a flapping connector still reaches the limit of its window
+				state.gaveUp = false
+				state.nextAttempt = time.Time{}
+				continue
+			}
+			if !r.filter.Match(name) || state.gaveUp || state.restarting || now.Before(state.nextAttempt) {
+				continue
+			}
+			if len(state.attempts) >= policy.maxAttempts {
+				state.gaveUp = true
+				r.counter(key).givenUp++
+				logger.Log("error", fmt.Sprintf("Giving up restarting connector %s of cluster %s after %d attempts within %s", name, host.Cluster, len(state.attempts), policy.window))
+				continue
+			}
+
+			state.attempts = append(state.attempts, now)
+			state.nextAttempt = now.Add(policy.backoff(len(state.attempts)))
+			r.counter(key).attempted++
+
+			if r.dryRun {
+				logger.Log("info", fmt.Sprintf("Dry run: would restart connector %s of cluster %s through %s", name, host.Cluster, host.Host))
+				continue
+			}
+			state.restarting = true
+			r.restarts.Add(1)
+			go r.restart(key, host)
+		}
+	}
+	r.forget(snapshot)
+}
+
+// Restart the connector through the host, with at most restartConcurrency restarts at once
+func (r *Remediator) restart(key connectorKey, host *poller.HostSnapshot) {
+	defer r.restarts.Done()
+	r.slots <- struct{}{}
+	defer func() { <-r.slots }()
+
+	logger.Log("info", fmt.Sprintf("Restarting connector %s of cluster %s through %s", key.connector, key.cluster, host.Host))
+	err := host.Collector.RestartConnector(host.Host, key.connector)
+	if err != nil {
+		logger.Log("error", applicationError.UnWrap(err).Stack)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, ok := r.states[key]
+	if !ok {
+		// the connector was removed in the meantime
+		return
+	}
+	state.restarting = false
+	if err == nil {
+		r.counter(key).succeeded++
+	}
+}
+
+// Drop the state and counters of the connectors that are no longer in their cluster, and of the clusters that
+// are no longer polled. Clusters without a healthy host keep their connectors until they can be listed again.
+func (r *Remediator) forget(snapshot *poller.Snapshot) {
+	polled := map[string]bool{}
+	for _, host := range snapshot.Hosts {
+		polled[host.Cluster] = true
+	}
+	listed := map[string]*poller.HostSnapshot{}
+	for _, host := range snapshot.ClusterHosts() {
+		listed[host.Cluster] = host
+	}
+
+	for key := range r.states {
+		host, listed := listed[key.cluster]
+		if !polled[key.cluster] || (listed && !hasConnector(host, key.connector)) {
+			delete(r.states, key)
+			delete(r.counters, key)
+		}
+	}
+}
+
+func (r *Remediator) state(key connectorKey) *restartState {
+	state, ok := r.states[key]
+	if !ok {
+		state = &restartState{}
+		r.states[key] = state
+	}
+	return state
+}
+
+func (r *Remediator) counter(key connectorKey) *restartCounters {
+	counter, ok := r.counters[key]
+	if !ok {
+		counter = &restartCounters{}
+		r.counters[key] = counter
+	}
+	return counter
+}
+
+func hasConnector(host *poller.HostSnapshot, connector string) bool {
+	_, ok := host.Connectors[connector]
+	return ok
+}
+
+// A connector needs a restart when the connector itself or any of its tasks failed
+func failed(status *collector.ConnectorStatus) bool {
+	if status == nil {
+		return false
+	}
+	if status.Connector.State == "FAILED" {
+		return true
+	}
+	for _, task := range status.Tasks {
+		if task.State == "FAILED" {
+			return true
+		}
+	}
+	return false
+}
+
+// Describe is a no-op, because the Collector dynamically allocates metrics.
+func (r *Remediator) Describe(ch chan<- *prometheus.Desc) {}
+
+func (r *Remediator) Collect(ch chan<- prometheus.Metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	dryRun := strconv.FormatBool(r.dryRun)
+	for key, counter := range r.counters {
+		ch <- prometheus.MustNewConstMetric(r.descAttempted, prometheus.CounterValue, float64(counter.attempted), key.cluster, key.connector, dryRun)
+		ch <- prometheus.MustNewConstMetric(r.descSucceeded, prometheus.CounterValue, float64(counter.succeeded), key.cluster, key.connector, dryRun)
+		ch <- prometheus.MustNewConstMetric(r.descGivenUp, prometheus.CounterValue, float64(counter.givenUp), key.cluster, key.connector, dryRun)
+	}
+}
@@ -0,0 +1,245 @@
+package remediation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/collector"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/config"
+	"github.com/Ecube-Labs/kafka-connect-exporter/internal/poller"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockRoundTripper struct {
+	roundTripFunc func(req *http.Request) (*http.Response, error)
+}
+
+func (m *mockRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return m.roundTripFunc(req)
+}
+
+// Records the restart requests and responds with the given status code
+func newRestartRecorder(statusCode int) (*collector.Collector, *[]string) {
+	var restarts []string
+	var mu sync.Mutex
+	roundTripper := &mockRoundTripper{
+		roundTripFunc: func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			restarts = append(restarts, req.URL.Host+req.URL.Path)
+			response := httptest.NewRecorder()
+			response.WriteHeader(statusCode)
+			return response.Result(), nil
+		},
+	}
+	return collector.New(&http.Client{Transport: roundTripper}), &restarts
+}
+
+func newSnapshot(c *collector.Collector, states map[string]string) *poller.Snapshot {
+	connectors := map[string]*collector.ExpandedConnector{}
+	for name, state := range states {
+		status := &collector.ConnectorStatus{Name: name, Tasks: []collector.ConnectorTaskStatus{{ID: 0, State: state}}}
+		status.Connector.State = "RUNNING"
+		connectors[name] = &collector.ExpandedConnector{Status: status}
+	}
+	return &poller.Snapshot{Hosts: []*poller.HostSnapshot{
+		{Cluster: "prod", Host: "http://connect-1", Collector: c, Err: assert.AnError},
+		{Cluster: "prod", Host: "http://connect-2", Collector: c, Connectors: connectors},
+	}}
+}
+
+func attempts(value int) *int {
+	return &value
+}
+
+func newRemediator(t *testing.T, settings config.Remediation) (*Remediator, *time.Time) {
+	if settings.DefaultPolicy.MaxAttempts == nil {
+		settings.DefaultPolicy = config.RestartPolicy{MaxAttempts: attempts(2), Window: time.Hour, InitialBackoff: time.Minute, MaxBackoff: 5 * time.Minute}
+	}
+	remediator, err := New(settings)
+	assert.Nil(t, err)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	remediator.now = func() time.Time { return now }
+	return remediator, &now
+}
+
+// Remediate the snapshot and wait for the restarts running in the background
+func remediate(remediator *Remediator, snapshot *poller.Snapshot) {
+	remediator.Remediate(snapshot)
+	remediator.restarts.Wait()
+}
+
+func TestRemediate(t *testing.T) {
+	t.Run("Should restart failed connectors through the first healthy host of the cluster", func(t *testing.T) {
+		c, restarts := newRestartRecorder(http.StatusAccepted)
+		remediator, _ := newRemediator(t, config.Remediation{})
+
+		remediate(remediator, newSnapshot(c, map[string]string{"connector1": "FAILED", "connector2": "RUNNING"}))
+
+		assert.Equal(t, []string{"connect-2/connectors/connector1/restart"}, *restarts)
+		expected := `
+# HELP kafka_connect_restarts_attempted_total Total number of automatic restarts of failed connectors and tasks
+# TYPE kafka_connect_restarts_attempted_total counter
+kafka_connect_restarts_attempted_total{cluster="prod",connector="connector1",dry_run="false"} 1
+# HELP kafka_connect_restarts_succeeded_total Total number of automatic restarts accepted by kafka connect
+# TYPE kafka_connect_restarts_succeeded_total counter
+kafka_connect_restarts_succeeded_total{cluster="prod",connector="connector1",dry_run="false"} 1
+`
+		assert.Nil(t, testutil.CollectAndCompare(remediator, strings.NewReader(expected), "kafka_connect_restarts_attempted_total", "kafka_connect_restarts_succeeded_total"))
+	})
+
+	t.Run("Should wait for the backoff and give up after the maximum number of attempts", func(t *testing.T) {
+		c, restarts := newRestartRecorder(http.StatusAccepted)
+		remediator, now := newRemediator(t, config.Remediation{})
+		snapshot := newSnapshot(c, map[string]string{"connector1": "FAILED"})
+
+		remediate(remediator, snapshot)
+		*now = now.Add(30 * time.Second)
+		remediate(remediator, snapshot)
+		assert.Len(t, *restarts, 1)
+
+		*now = now.Add(30 * time.Second)
+		remediate(remediator, snapshot)
+		assert.Len(t, *restarts, 2)
+
+		// the second backoff is doubled
+		*now = now.Add(2 * time.Minute)
+		remediate(remediator, snapshot)
+		remediate(remediator, snapshot)
+		assert.Len(t, *restarts, 2)
+
+		expected := `
+# HELP kafka_connect_restarts_given_up_total Total number of times the restart policy gave up on a failed connector
+# TYPE kafka_connect_restarts_given_up_total counter
+kafka_connect_restarts_given_up_total{cluster="prod",connector="connector1",dry_run="false"} 1
+`
+		assert.Nil(t, testutil.CollectAndCompare(remediator, strings.NewReader(expected), "kafka_connect_restarts_given_up_total"))
+
+		// attempts expire with the window
+		*now = now.Add(time.Hour)
+		remediate(remediator, newSnapshot(c, map[string]string{"connector1": "RUNNING"}))
+		remediate(remediator, snapshot)
+		assert.Len(t, *restarts, 3)
+	})
+
+	t.Run("Should try again once the window passed while the connector is still failed", func(t *testing.T) {
+		c, restarts := newRestartRecorder(http.StatusAccepted)
+		remediator, now := newRemediator(t, config.Remediation{})
+		snapshot := newSnapshot(c, map[string]string{"connector1": "FAILED"})
+
+		remediate(remediator, snapshot)
+		*now = now.Add(time.Minute)
+		remediate(remediator, snapshot)
+		*now = now.Add(2 * time.Minute)
+		remediate(remediator, snapshot)
+		assert.Len(t, *restarts, 2)
+
+		*now = now.Add(time.Hour)
+		remediate(remediator, snapshot)
+		assert.Len(t, *restarts, 3)
+	})
+
+	t.Run("Should restart in the background without waiting for the worker", func(t *testing.T) {
+		release := make(chan struct{})
+		roundTripper := &mockRoundTripper{
+			roundTripFunc: func(req *http.Request) (*http.Response, error) {
+				<-release
+				response := httptest.NewRecorder()
+				response.WriteHeader(http.StatusAccepted)
+				return response.Result(), nil
+			},
+		}
+		remediator, _ := newRemediator(t, config.Remediation{})
+
+		remediator.Remediate(newSnapshot(collector.New(&http.Client{Transport: roundTripper}), map[string]string{"connector1": "FAILED"}))
+		close(release)
+		remediator.restarts.Wait()
+
+		expected := `
+# HELP kafka_connect_restarts_succeeded_total Total number of automatic restarts accepted by kafka connect
+# TYPE kafka_connect_restarts_succeeded_total counter
+kafka_connect_restarts_succeeded_total{cluster="prod",connector="connector1",dry_run="false"} 1
+`
+		assert.Nil(t, testutil.CollectAndCompare(remediator, strings.NewReader(expected), "kafka_connect_restarts_succeeded_total"))
+	})
+
+	t.Run("Should forget the connectors that were removed and the clusters that are no longer polled", func(t *testing.T) {
+		c, _ := newRestartRecorder(http.StatusAccepted)
+		remediator, _ := newRemediator(t, config.Remediation{})
+
+		remediate(remediator, newSnapshot(c, map[string]string{"connector1": "FAILED", "connector2": "FAILED"}))
+		assert.Len(t, remediator.states, 2)
+
+		// the connectors of a cluster without a healthy host are kept
+		remediate(remediator, &poller.Snapshot{Hosts: []*poller.HostSnapshot{{Cluster: "prod", Host: "http://connect-1", Err: assert.AnError}}})
+		assert.Len(t, remediator.states, 2)
+
+		remediate(remediator, newSnapshot(c, map[string]string{"connector1": "RUNNING"}))
+		assert.Len(t, remediator.states, 1)
+		assert.Len(t, remediator.counters, 1)
+
+		remediate(remediator, &poller.Snapshot{})
+		assert.Empty(t, remediator.states)
+		assert.Empty(t, remediator.counters)
+	})
+
+	t.Run("Should not count a rejected restart as succeeded", func(t *testing.T) {
+		c, restarts := newRestartRecorder(http.StatusConflict)
+		remediator, _ := newRemediator(t, config.Remediation{})
+
+		remediate(remediator, newSnapshot(c, map[string]string{"connector1": "FAILED"}))
+
+		assert.Len(t, *restarts, 1)
+		expected := `
+# HELP kafka_connect_restarts_succeeded_total Total number of automatic restarts accepted by kafka connect
+# TYPE kafka_connect_restarts_succeeded_total counter
+kafka_connect_restarts_succeeded_total{cluster="prod",connector="connector1",dry_run="false"} 0
+`
+		assert.Nil(t, testutil.CollectAndCompare(remediator, strings.NewReader(expected), "kafka_connect_restarts_succeeded_total"))
+	})
+
+	t.Run("Should skip excluded connectors and only count restarts in dry run", func(t *testing.T) {
+		c, restarts := newRestartRecorder(http.StatusAccepted)
+		remediator, _ := newRemediator(t, config.Remediation{DryRun: true, Exclude: []string{"legacy-.*"}})
+
+		remediate(remediator, newSnapshot(c, map[string]string{"connector1": "FAILED", "legacy-sink": "FAILED"}))
+
+		assert.Empty(t, *restarts)
+		expected := `
+# HELP kafka_connect_restarts_attempted_total Total number of automatic restarts of failed connectors and tasks
+# TYPE kafka_connect_restarts_attempted_total counter
+kafka_connect_restarts_attempted_total{cluster="prod",connector="connector1",dry_run="true"} 1
+`
+		assert.Nil(t, testutil.CollectAndCompare(remediator, strings.NewReader(expected), "kafka_connect_restarts_attempted_total"))
+	})
+
+	t.Run("Should apply the first matching policy", func(t *testing.T) {
+		c, restarts := newRestartRecorder(http.StatusAccepted)
+		remediator, _ := newRemediator(t, config.Remediation{Policies: []config.RestartPolicy{
+			{Connectors: []string{"critical-.*"}, MaxAttempts: attempts(3), Window: time.Hour},
+		}})
+		snapshot := newSnapshot(c, map[string]string{"critical-sink": "FAILED"})
+
+		// without backoff every poll restarts the connector until the limit
+		for i := 0; i < 4; i++ {
+			remediate(remediator, snapshot)
+		}
+		assert.Len(t, *restarts, 3)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	t.Run("Should double the backoff up to the maximum", func(t *testing.T) {
+		policy := &policy{initialBackoff: time.Minute, maxBackoff: 5 * time.Minute}
+		assert.Equal(t, time.Minute, policy.backoff(1))
+		assert.Equal(t, 2*time.Minute, policy.backoff(2))
+		assert.Equal(t, 4*time.Minute, policy.backoff(3))
+		assert.Equal(t, 5*time.Minute, policy.backoff(4))
+	})
+}
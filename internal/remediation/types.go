@@ -0,0 +1,32 @@
+package remediation
+
+import "time"
+
+type connectorKey struct {
+	cluster   string
+	connector string
+}
+
+type restartState struct {
+	// times of the restarts within the window of the policy
+	attempts    []time.Time
+	nextAttempt time.Time
+	gaveUp      bool
+	// whether a restart of the connector is running
+	restarting bool
+}
+
+// Drop the attempts that are older than the window
+func (s *restartState) prune(now time.Time, window time.Duration) {
+	i := 0
+	for i < len(s.attempts) && now.Sub(s.attempts[i]) >= window {
+		i++
+	}
+	s.attempts = s.attempts[i:]
+}
+
+type restartCounters struct {
+	attempted uint64
+	succeeded uint64
+	givenUp   uint64
+}
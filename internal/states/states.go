@@ -0,0 +1,8 @@
+// The states of connectors and tasks reported by kafka connect, shared by the config and the exporters
+package states
+
+// States of tasks reported by kafka connect
+var Tasks = []string{"RUNNING", "PAUSED", "FAILED", "UNASSIGNED", "RESTARTING"}
+
+// States of connectors reported by kafka connect, a connector can also be STOPPED since kafka 3.5
+var Connectors = []string{"RUNNING", "PAUSED", "FAILED", "UNASSIGNED", "RESTARTING", "STOPPED"}
@@ -1,6 +1,7 @@
 package applicationError
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"runtime"
@@ -67,8 +68,10 @@ func Wrap(err error) error {
 	return httpErr.stackTrace()
 }
 
+// The application error of err, which may be wrapped by another error
 func UnWrap(err error) *httpError {
-	if e, ok := err.(*httpError); ok {
+	var e *httpError
+	if errors.As(err, &e) {
 		return e
 	}
 	// NOTE: Set status with 500 when error is not application error
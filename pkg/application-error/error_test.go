@@ -2,6 +2,7 @@ package applicationError
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -124,4 +125,10 @@ func TestUnWrap(t *testing.T) {
 		assert.Contains(t, stackTraces[0], "Error: Internal Server Error")
 		assert.Contains(t, stackTraces[1], "unwrapError")
 	})
+	t.Run("Should return the httpError wrapped by another error", func(t *testing.T) {
+		err := UnWrap(fmt.Errorf("request failed: %w", httpErrorFuncOne()))
+
+		assert.Equal(t, 500, err.Code)
+		assert.Contains(t, err.Stack, "httpErrorFuncOne")
+	})
 }